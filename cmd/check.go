@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+)
+
+// checkResult represents the result of a step on a host in check mode.
+type checkResult struct {
+	host    string
+	item    interface{}
+	changed bool
+	skipped bool
+	notify  string
+	err     error
+}
+
+// checkReport collects the check mode results of all steps.
+type checkReport struct {
+	steps   []string
+	results map[string][]checkResult
+
+	mux sync.Mutex
+}
+
+// newCheckReport will return an empty checkReport.
+func newCheckReport() *checkReport {
+	return &checkReport{
+		results: make(map[string][]checkResult),
+	}
+}
+
+// addStep registers a step with the report.
+// Steps are printed in the order they were added.
+func (r *checkReport) addStep(step string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.steps = append(r.steps, step)
+}
+
+// add records the result of a step on a host.
+func (r *checkReport) add(step string, result checkResult) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.results[step] = append(r.results[step], result)
+}
+
+// print prints the report in a format similar to a plan.
+func (r *checkReport) print(taskName string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+
+	title.Printf("yak check - %s\n", taskName)
+	fmt.Println("")
+
+	for _, step := range r.steps {
+		cyan.Println(step)
+
+		results := r.results[step]
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].host < results[j].host
+		})
+
+		for _, result := range results {
+			var status string
+			switch {
+			case result.err != nil:
+				status = red.Sprintf("error: %s", result.err)
+			case result.skipped:
+				status = cyan.Sprint("skipped")
+			case result.changed:
+				status = yellow.Sprint("would change")
+			default:
+				status = green.Sprint("ok")
+			}
+
+			name := fmt.Sprintf("host=%s", result.host)
+			if result.item != nil {
+				name = fmt.Sprintf("%s item=%v", name, result.item)
+			}
+
+			fmt.Fprintln(w, magenta.Sprintf("  - %s\t", name)+status)
+
+			if result.changed && result.notify != "" {
+				fmt.Fprintln(w, blue.Sprintf("    - would notify: %s", result.notify))
+			}
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	eventTaskStart   = "task_start"
+	eventStepStart   = "step_start"
+	eventHostResult  = "host_result"
+	eventNotifierRun = "notifier_run"
+	eventTaskEnd     = "task_end"
+)
+
+// eventBase represents the fields which all events have.
+type eventBase struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Task string    `json:"task"`
+}
+
+// taskStartEvent is emitted before the first step of a task.
+type taskStartEvent struct {
+	eventBase
+	Check bool `json:"check"`
+	Steps int  `json:"steps"`
+}
+
+// stepStartEvent is emitted before a step is run on its hosts.
+type stepStartEvent struct {
+	eventBase
+	Step  string   `json:"step"`
+	Index int      `json:"index"`
+	Hosts []string `json:"hosts"`
+}
+
+// hostResultEvent is emitted after a step or notifier has been run
+// on a host. A step with a loop emits an event for each item.
+type hostResultEvent struct {
+	eventBase
+	Step     string      `json:"step"`
+	Notifier string      `json:"notifier,omitempty"`
+	Host     string      `json:"host"`
+	Item     interface{} `json:"item,omitempty"`
+	Status   string      `json:"status"`
+	Changed  bool        `json:"changed"`
+	ExitCode int         `json:"exit_code"`
+	Stdout   string      `json:"stdout"`
+	Stderr   string      `json:"stderr"`
+	Duration float64     `json:"duration"`
+	Error    string      `json:"error,omitempty"`
+	Resource string      `json:"resource,omitempty"`
+	Name     string      `json:"name,omitempty"`
+	State    string      `json:"state,omitempty"`
+}
+
+// taskEndEvent is emitted once a task has finished. It contains the
+// recap of the run.
+type taskEndEvent struct {
+	eventBase
+	Status   string                  `json:"status"`
+	Duration float64                 `json:"duration"`
+	Hosts    map[string]*recapCounts `json:"hosts"`
+}
+
+// eventStream writes events as JSON, one event per line.
+// A nil eventStream discards all events.
+type eventStream struct {
+	task string
+	enc  *json.Encoder
+	mux  sync.Mutex
+}
+
+// newEventStream will return an eventStream for a task which
+// writes to w.
+func newEventStream(w io.Writer, task string) *eventStream {
+	return &eventStream{
+		task: task,
+		enc:  json.NewEncoder(w),
+	}
+}
+
+// base returns the common fields of an event.
+func (r *eventStream) base(eventType string) eventBase {
+	return eventBase{
+		Type: eventType,
+		Time: time.Now().UTC(),
+		Task: r.task,
+	}
+}
+
+// emit writes an event.
+func (r *eventStream) emit(event interface{}) {
+	if r == nil {
+		return
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.enc.Encode(event)
+}
+
+// taskStart emits a task_start event.
+func (r *eventStream) taskStart(check bool, steps int) {
+	if r == nil {
+		return
+	}
+
+	r.emit(taskStartEvent{
+		eventBase: r.base(eventTaskStart),
+		Check:     check,
+		Steps:     steps,
+	})
+}
+
+// stepStart emits a step_start event.
+func (r *eventStream) stepStart(step string, index int, hosts []string) {
+	if r == nil {
+		return
+	}
+
+	r.emit(stepStartEvent{
+		eventBase: r.base(eventStepStart),
+		Step:      step,
+		Index:     index,
+		Hosts:     hosts,
+	})
+}
+
+// hostResult emits a host_result event for each iteration of a
+// step's result. If notifier is set, notifier_run events are
+// emitted instead.
+func (r *eventStream) hostResult(host, step, notifier string, ignoreErrors bool, result stepResult) {
+	if r == nil {
+		return
+	}
+
+	eventType := eventHostResult
+	if notifier != "" {
+		eventType = eventNotifierRun
+	}
+
+	for _, v := range result.iterations() {
+		event := hostResultEvent{
+			eventBase: r.base(eventType),
+			Step:      step,
+			Notifier:  notifier,
+			Host:      host,
+			Item:      v.item,
+			Status:    resultStatus(v, ignoreErrors),
+			Changed:   v.Changed,
+			ExitCode:  v.ExitCode,
+			Stdout:    v.Stdout,
+			Stderr:    v.Stderr,
+			Duration:  v.duration.Seconds(),
+			Resource:  v.Resource,
+			Name:      v.Name,
+			State:     v.State,
+		}
+
+		if v.err != nil {
+			event.Error = v.err.Error()
+		}
+
+		r.emit(event)
+	}
+}
+
+// taskEnd emits a task_end event with the recap of the run.
+func (r *eventStream) taskEnd(status string, recap *recap) {
+	if r == nil {
+		return
+	}
+
+	recap.mux.Lock()
+	defer recap.mux.Unlock()
+
+	r.emit(taskEndEvent{
+		eventBase: r.base(eventTaskEnd),
+		Status:    status,
+		Duration:  recap.Duration,
+		Hosts:     recap.Hosts,
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/jtopjian/yak/lib/facts"
+	"github.com/jtopjian/yak/lib/yakfile"
+
+	"github.com/remeh/sizedwaitgroup"
+	"github.com/urfave/cli"
+)
+
+// FactsDefaultLimit is the number of hosts to gather facts
+// from at once.
+const FactsDefaultLimit = 5
+
+func actionFacts(c *cli.Context) error {
+	log := getLogger()
+
+	// Make sure a target was specified.
+	if c.NArg() == 0 {
+		return fmt.Errorf("no target specified")
+	}
+	targetName := c.Args()[0]
+
+	herd, err := newHerd(c)
+	if err != nil {
+		return err
+	}
+
+	// Build an ad-hoc step to discover the hosts of the target.
+	step := yakfile.Step{
+		Name:    "facts",
+		Targets: []string{targetName},
+	}
+
+	hosts, err := herd.GetHostsForStep(step)
+	if err != nil {
+		return err
+	}
+
+	var mux sync.Mutex
+	var errored bool
+	results := make(map[string]*facts.Facts)
+
+	swg := sizedwaitgroup.New(FactsDefaultLimit)
+	for i := range hosts {
+		swg.Add()
+		go func(host *yakfile.Host) {
+			defer swg.Done()
+
+			log.Debugf("attempting to connect to %s via %s",
+				host.Name, host.ConnectionType)
+
+			f, err := gatherFacts(host)
+
+			mux.Lock()
+			defer mux.Unlock()
+
+			if err != nil {
+				log.Errorf("unable to gather facts of %s: %s", host.Name, err)
+				errored = true
+				return
+			}
+
+			results[host.Name] = f
+		}(&hosts[i])
+	}
+	swg.Wait()
+
+	b, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(b))
+
+	if errored {
+		return fmt.Errorf("unable to gather facts of all hosts")
+	}
+
+	return nil
+}
+
+// gatherFacts will connect to a host and gather its facts.
+func gatherFacts(host *yakfile.Host) (*facts.Facts, error) {
+	if err := host.Connection.Connect(); err != nil {
+		return nil, err
+	}
+	defer host.Connection.Close()
+
+	return facts.Gather(host.Connection)
+}
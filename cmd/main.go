@@ -8,6 +8,11 @@ import (
 )
 
 var (
+	checkFlag = cli.BoolFlag{
+		Name:  "check",
+		Usage: "report what would change without changing it",
+	}
+
 	configFlag = cli.StringFlag{
 		Name:   "config",
 		Usage:  "yak configuration file",
@@ -26,6 +31,27 @@ var (
 		EnvVar: "YAK_DIR,DIR",
 		Value:  ".",
 	}
+
+	limitFlag = cli.StringFlag{
+		Name:  "limit",
+		Usage: "only run steps on the hosts of a target expression",
+	}
+
+	outputFlag = cli.StringFlag{
+		Name:  "output",
+		Usage: "output format of a run: text or json",
+		Value: "text",
+	}
+
+	recapFileFlag = cli.StringFlag{
+		Name:  "recap-file",
+		Usage: "write the recap of the run to a file as JSON",
+	}
+
+	streamFlag = cli.BoolFlag{
+		Name:  "stream",
+		Usage: "show the output of commands as it is produced",
+	}
 )
 
 func main() {
@@ -44,6 +70,24 @@ func main() {
 			Usage:  "run a task",
 			Before: before,
 			Action: actionRun,
+			Flags: []cli.Flag{
+				checkFlag,
+				configFlag,
+				debugFlag,
+				dirFlag,
+				limitFlag,
+				outputFlag,
+				recapFileFlag,
+				streamFlag,
+			},
+		},
+
+		cli.Command{
+			Name:      "facts",
+			Usage:     "show the facts of the hosts of a target",
+			ArgsUsage: "target",
+			Before:    before,
+			Action:    actionFacts,
 			Flags: []cli.Flag{
 				configFlag,
 				debugFlag,
@@ -60,13 +104,14 @@ func main() {
 				configFlag,
 				debugFlag,
 				dirFlag,
+				limitFlag,
 			},
 		},
 	}
 
 	err := app.Run(os.Args)
 	if err != nil {
-		fmt.Printf("Error: %s\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(1)
 	}
 }
@@ -26,6 +26,12 @@ func actionPlan(c *cli.Context) error {
 		return err
 	}
 
+	// Get the hosts of each step.
+	hosts, err := herd.GetHostsForSteps(steps, c.String("limit"))
+	if err != nil {
+		return err
+	}
+
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
 
 	// Print the plan.
@@ -33,23 +39,27 @@ func actionPlan(c *cli.Context) error {
 	fmt.Println("")
 
 	// For each step in the task.
-	for _, step := range steps {
+	for i, step := range steps {
 		// Print the step name.
 		cyan.Println(step.Name)
 
-		// Get the hosts required for the step.
-		stepHosts, err := herd.GetHostsForStep(step)
-		if err != nil {
-			return err
-		}
-
 		// For each host
-		for _, host := range stepHosts {
+		for _, host := range hosts[i] {
 			// Print the hosts which the step will be run on.
 			fmt.Fprintln(w, magenta.Sprintf("  - host=%s\ttarget=\"%s\"\tconnection=\"%s\"",
 				host.Name, host.TargetName, host.ConnectionName))
 		}
 
+		// Print the loop of the step.
+		if step.Loop != nil {
+			fmt.Fprintln(w, blue.Sprintf("  - loop: %v", step.Loop))
+		}
+
+		// Print the condition of the step.
+		if step.When != "" {
+			fmt.Fprintln(w, blue.Sprintf("  - when: %s", step.When))
+		}
+
 		// Print any notification actions to run.
 		if step.Notify != "" {
 			fmt.Fprintln(w, blue.Sprintf("  - notify: %s", step.Notify))
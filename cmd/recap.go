@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jtopjian/yak/lib/yakfile"
+)
+
+// The possible outcomes of a step on a host.
+const (
+	statusOk          = "ok"
+	statusChanged     = "changed"
+	statusFailed      = "failed"
+	statusIgnored     = "ignored"
+	statusSkipped     = "skipped"
+	statusUnreachable = "unreachable"
+)
+
+// statusRank orders the outcomes of the iterations of a loop. The
+// outcome of a step with a loop is the highest outcome of its
+// iterations.
+var statusRank = map[string]int{
+	statusSkipped:     0,
+	statusOk:          1,
+	statusChanged:     2,
+	statusIgnored:     3,
+	statusFailed:      4,
+	statusUnreachable: 5,
+}
+
+// resultStatus returns the outcome of a step on a host. A step with
+// a loop has failed if any iteration failed, has changed if any
+// iteration changed, and is skipped if all iterations were skipped.
+func resultStatus(result stepResult, ignoreErrors bool) string {
+	if len(result.loop) > 0 && !result.unreachable {
+		status := statusSkipped
+		for _, v := range result.loop {
+			if s := resultStatus(v, ignoreErrors); statusRank[s] > statusRank[status] {
+				status = s
+			}
+		}
+
+		return status
+	}
+
+	switch {
+	case result.unreachable:
+		return statusUnreachable
+	case result.err != nil && ignoreErrors:
+		return statusIgnored
+	case result.err != nil:
+		return statusFailed
+	case result.skipped:
+		return statusSkipped
+	case result.Changed:
+		return statusChanged
+	}
+
+	return statusOk
+}
+
+// recapCounts represents the amount of steps of each outcome on
+// a host.
+type recapCounts struct {
+	Ok          int `json:"ok"`
+	Changed     int `json:"changed"`
+	Failed      int `json:"failed"`
+	Ignored     int `json:"ignored"`
+	Skipped     int `json:"skipped"`
+	Unreachable int `json:"unreachable"`
+
+	// Items counts each iteration of the steps with a loop. It is
+	// nil if no step on the host had a loop.
+	Items *recapCounts `json:"items,omitempty"`
+}
+
+// count counts an outcome.
+func (r *recapCounts) count(status string) {
+	switch status {
+	case statusUnreachable:
+		r.Unreachable++
+	case statusIgnored:
+		r.Ignored++
+	case statusFailed:
+		r.Failed++
+	case statusSkipped:
+		r.Skipped++
+	case statusChanged:
+		r.Changed++
+	default:
+		r.Ok++
+	}
+}
+
+// String returns the counts as a row of the recap table.
+func (r *recapCounts) String() string {
+	return fmt.Sprintf("ok=%d\tchanged=%d\tfailed=%d\tignored=%d\tskipped=%d\tunreachable=%d",
+		r.Ok, r.Changed, r.Failed, r.Ignored, r.Skipped, r.Unreachable)
+}
+
+// recap collects the results of all steps of a run.
+type recap struct {
+	Task     string                  `json:"task"`
+	Duration float64                 `json:"duration"`
+	Hosts    map[string]*recapCounts `json:"hosts"`
+
+	start time.Time
+	mux   sync.Mutex
+}
+
+// newRecap will return an empty recap for a task.
+// The duration of the run is measured from when the recap is created.
+func newRecap(taskName string) *recap {
+	return &recap{
+		Task:  taskName,
+		Hosts: make(map[string]*recapCounts),
+		start: time.Now(),
+	}
+}
+
+// add records the result of a step or notifier on a host. A step with
+// a loop is counted once, and each of its iterations is also counted
+// in the items of the host.
+func (r *recap) add(host string, step yakfile.Step, result stepResult) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	counts, ok := r.Hosts[host]
+	if !ok {
+		counts = &recapCounts{}
+		r.Hosts[host] = counts
+	}
+
+	counts.count(resultStatus(result, step.IgnoreErrors))
+
+	if len(result.loop) == 0 || result.unreachable {
+		return
+	}
+
+	if counts.Items == nil {
+		counts.Items = &recapCounts{}
+	}
+
+	for _, v := range result.loop {
+		counts.Items.count(resultStatus(v, step.IgnoreErrors))
+	}
+}
+
+// finish records the duration of the run.
+func (r *recap) finish() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.Duration = time.Since(r.start).Seconds()
+}
+
+// print prints the recap as a table.
+func (r *recap) print() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+
+	title.Printf("yak recap - %s\n", r.Task)
+	fmt.Println("")
+
+	var hosts []string
+	for host := range r.Hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	for _, host := range hosts {
+		c := r.Hosts[host]
+
+		name := magenta
+		if c.Failed > 0 || c.Unreachable > 0 {
+			name = red
+		}
+
+		fmt.Fprintln(w, name.Sprintf("%s\t", host)+c.String())
+
+		if c.Items != nil {
+			fmt.Fprintln(w, cyan.Sprintf("  items\t")+c.Items.String())
+		}
+	}
+	w.Flush()
+
+	fmt.Println("")
+	fmt.Printf("elapsed: %s\n", time.Duration(r.Duration*float64(time.Second)).Round(time.Millisecond))
+}
+
+// write writes the recap to a file as JSON.
+func (r *recap) write(file string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode recap: %s", err)
+	}
+
+	if err := ioutil.WriteFile(file, b, 0644); err != nil {
+		return fmt.Errorf("unable to write recap to %s: %s", file, err)
+	}
+
+	return nil
+}
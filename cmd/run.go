@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"sync"
 
 	"github.com/jtopjian/yak/lib/yakfile"
 
 	"github.com/remeh/sizedwaitgroup"
+	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 )
 
@@ -23,25 +27,94 @@ func actionRun(c *cli.Context) error {
 		return err
 	}
 
+	state, err := newRunState(herd)
+	if err != nil {
+		return err
+	}
+
+	// All connections are closed once the run has finished.
+	defer state.conns.CloseAll()
+
+	// In check mode, steps only report what they would change.
+	check := c.Bool("check")
+	report := newCheckReport()
+
+	// The recap collects the results of all steps.
+	recap := newRecap(taskName)
+
+	// In json output mode, an event is written to stdout for each
+	// part of the run instead of the text reports.
+	var events *eventStream
+	switch output := c.String("output"); output {
+	case "text":
+	case "json":
+		events = newEventStream(os.Stdout, taskName)
+		log.Formatter = &logrus.JSONFormatter{}
+	default:
+		return fmt.Errorf("invalid output: %s", output)
+	}
+
+	// When streaming, the output of commands is shown as it is
+	// produced.
+	if c.Bool("stream") {
+		if events != nil {
+			return fmt.Errorf("--stream can't be used with json output")
+		}
+
+		state.stream = newOutputStream(os.Stdout)
+	}
+
 	log.Infof("===> Task: %s", taskName)
 
-	// Get the steps of the task.
-	steps, err := herd.ListStepsForTask(taskName)
+	// Get the task and its steps.
+	task, err := herd.GetTask(taskName)
+	if err != nil {
+		return err
+	}
+	steps := task.Steps
+
+	// The hosts of every step are selected before the run starts, so
+	// a --limit which selects no hosts is an error.
+	hosts, err := herd.GetHostsForSteps(steps, c.String("limit"))
 	if err != nil {
 		return err
 	}
 
+	events.taskStart(check, len(steps))
+
+	var aborted bool
+
 	// For each step in the task.
 	for i, step := range steps {
 		log.Infof("===> Step [%02d/%02d]: %s", i+1, len(steps), step.Name)
 
-		stepHosts, err := herd.GetHostsForStep(step)
-		if err != nil {
-			return err
+		stepHosts := hosts[i]
+
+		if check {
+			report.addStep(step.Name)
+		}
+
+		var hostNames []string
+		for j := range stepHosts {
+			if !state.hasFailed(&stepHosts[j]) {
+				hostNames = append(hostNames, stepHosts[j].Name)
+			}
 		}
+		events.stepStart(step.Name, i+1, hostNames)
+
+		// Track the hosts which failed this step.
+		var stepHostCount, stepFailedCount int
+		var mux sync.Mutex
 
 		swg := sizedwaitgroup.New(step.Limit)
 		for _, host := range stepHosts {
+			// Hosts which failed an earlier step are skipped.
+			if state.hasFailed(&host) {
+				log.Debugf("skipping failed host %s", host.Name)
+				continue
+			}
+			stepHostCount++
+
 			// Create a goroutine for each task execution.
 			// Limit the amount of goroutines running at a
 			// time by the task.Limit setting.
@@ -50,11 +123,37 @@ func actionRun(c *cli.Context) error {
 				defer swg.Done()
 
 				ctx := context.WithValue(context.Background(), "log", log)
-				changed, err := runStep(ctx, host, step)
+				ctx = context.WithValue(ctx, "check", check)
+				result := runStep(ctx, state, host, step)
+				recap.add(host.Name, step, result)
+				events.hostResult(host.Name, step.Name, "", step.IgnoreErrors, result)
+
+				if failed := checkFailure(log, state, &host, step, result); failed {
+					mux.Lock()
+					stepFailedCount++
+					mux.Unlock()
+				}
+
+				// In check mode, record the result and never run
+				// a notifier.
+				if check {
+					for _, r := range result.iterations() {
+						report.add(step.Name, checkResult{
+							host:    host.Name,
+							item:    r.item,
+							changed: r.Changed,
+							skipped: r.skipped,
+							notify:  step.Notify,
+							err:     r.err,
+						})
+					}
+
+					return
+				}
 
 				// if a change was made and there was no error,
 				// run a notifier if one exists.
-				if changed && err == nil && step.Notify != "" {
+				if result.Changed && result.err == nil && step.Notify != "" {
 					n, err := herd.GetNotify(step.Notify)
 					if err != nil {
 						log.Errorf("unable to determine notify for step %s", step.Name)
@@ -63,14 +162,100 @@ func actionRun(c *cli.Context) error {
 
 					if err == nil {
 						ctx := context.WithValue(context.Background(), "log", log)
-						runStep(ctx, host, *n)
+						result := runStep(ctx, state, host, *n)
+						recap.add(host.Name, *n, result)
+						events.hostResult(host.Name, step.Name, n.Name, n.IgnoreErrors, result)
+
+						// A failed notifier fails the host as part
+						// of the step which notified it.
+						if failed := checkFailure(log, state, &host, *n, result); failed {
+							mux.Lock()
+							stepFailedCount++
+							mux.Unlock()
+						}
 					}
 				}
 			}(step, host)
 		}
 		swg.Wait()
 		log.Info("")
+
+		if stepFailedCount == 0 {
+			continue
+		}
+
+		// Determine if the task should be stopped.
+		if task.Defaults.AnyErrorsFatal {
+			log.Errorf("===> Aborting: step %s failed on %d host(s) and any_errors_fatal is set",
+				step.Name, stepFailedCount)
+			aborted = true
+			break
+		}
+
+		if v := task.Defaults.MaxFailPercentage; v != nil {
+			percentage := float64(stepFailedCount) / float64(stepHostCount) * 100
+			if percentage > float64(*v) {
+				log.Errorf("===> Aborting: step %s failed on %.0f%% of hosts, which is more than max_fail_percentage of %d%%",
+					step.Name, percentage, *v)
+				aborted = true
+				break
+			}
+		}
+	}
+
+	recap.finish()
+
+	status := "ok"
+	switch {
+	case aborted:
+		status = "aborted"
+	case state.failedCount() > 0:
+		status = "failed"
+	}
+
+	if events != nil {
+		events.taskEnd(status, recap)
+	} else {
+		if check {
+			report.print(taskName)
+			fmt.Println("")
+		}
+
+		recap.print()
+	}
+
+	if v := c.String("recap-file"); v != "" {
+		if err := recap.write(v); err != nil {
+			return err
+		}
+	}
+
+	if aborted {
+		return fmt.Errorf("task %s was aborted", taskName)
+	}
+
+	if n := state.failedCount(); n > 0 {
+		return fmt.Errorf("task %s failed on %d host(s)", taskName, n)
 	}
 
 	return nil
 }
+
+// checkFailure determines if a step failed on a host. If the error
+// of the step is not ignored, the host is marked as failed.
+func checkFailure(log *logrus.Logger, state *runState, host *yakfile.Host, step yakfile.Step, result stepResult) bool {
+	if result.err == nil {
+		return false
+	}
+
+	if step.IgnoreErrors {
+		log.WithFields(logrus.Fields{
+			"host": host.Name,
+		}).Warnf("ignoring error of step %s", step.Name)
+		return false
+	}
+
+	state.setFailed(host)
+
+	return true
+}
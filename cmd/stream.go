@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// streamColors are the colors which hosts are shown in when the
+// output of commands is streamed.
+var streamColors = []*color.Color{cyan, blue, magenta, green, yellow}
+
+// outputStream writes the output of commands as it is produced.
+// Each line is prefixed with the name of its host in a color which
+// is always the same for the host.
+type outputStream struct {
+	out io.Writer
+	mux sync.Mutex
+}
+
+// newOutputStream will return an outputStream which writes to out.
+func newOutputStream(out io.Writer) *outputStream {
+	return &outputStream{
+		out: out,
+	}
+}
+
+// writer returns a writer for the output of a host.
+func (r *outputStream) writer(host string) io.Writer {
+	h := fnv.New32a()
+	h.Write([]byte(host))
+	c := streamColors[h.Sum32()%uint32(len(streamColors))]
+
+	return &hostWriter{
+		stream: r,
+		prefix: c.Sprintf("%s |", host),
+	}
+}
+
+// println writes a single line. Lines of different hosts are never
+// mixed together.
+func (r *outputStream) println(prefix, line string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	fmt.Fprintln(r.out, prefix, line)
+}
+
+// hostWriter is a writer for the output of a host. Output is written
+// to the stream one line at a time. stdout and stderr of a command are
+// written at the same time, so the buffer is locked.
+type hostWriter struct {
+	stream *outputStream
+	prefix string
+	buf    bytes.Buffer
+	mux    sync.Mutex
+}
+
+// Write implements io.Writer. A partial line is kept until the rest
+// of the line is written or the writer is flushed.
+func (r *hostWriter) Write(p []byte) (int, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.buf.Write(p)
+
+	for {
+		line, err := r.buf.ReadString('\n')
+		if err != nil {
+			r.buf.Reset()
+			r.buf.WriteString(line)
+			break
+		}
+
+		r.stream.println(r.prefix, strings.TrimRight(line, "\r\n"))
+	}
+
+	return len(p), nil
+}
+
+// Flush will write a partial line which was kept. It is called once
+// a command has finished.
+func (r *hostWriter) Flush() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.buf.Len() > 0 {
+		r.stream.println(r.prefix, strings.TrimRight(r.buf.String(), "\r"))
+		r.buf.Reset()
+	}
+
+	return nil
+}
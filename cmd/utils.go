@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"path"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/fatih/color"
 
@@ -13,6 +15,8 @@ import (
 	"github.com/urfave/cli"
 
 	"github.com/jtopjian/yak/lib/actions"
+	"github.com/jtopjian/yak/lib/connections"
+	"github.com/jtopjian/yak/lib/facts"
 	"github.com/jtopjian/yak/lib/yakfile"
 )
 
@@ -21,6 +25,9 @@ var (
 	cyan    = color.New(color.FgCyan)
 	blue    = color.New(color.FgBlue)
 	magenta = color.New(color.FgMagenta)
+	green   = color.New(color.FgGreen)
+	yellow  = color.New(color.FgYellow)
+	red     = color.New(color.FgRed)
 )
 
 // newHerd will build a herd given a directory.
@@ -59,25 +66,301 @@ func getTask(c *cli.Context) (string, error) {
 	return c.Args()[0], nil
 }
 
+// runState holds information which is shared by all steps of a run.
+type runState struct {
+	conns      *connections.Pool
+	facts      *facts.Cache
+	vars       map[string]interface{}
+	results    map[string]map[string]interface{}
+	registered map[string]map[string]interface{}
+	failed     map[string]bool
+
+	// stream is set when the output of commands is streamed.
+	stream *outputStream
+
+	mux sync.Mutex
+}
+
+// newRunState will return a runState for a herd.
+func newRunState(herd yakfile.Herd) (*runState, error) {
+	vars, err := herd.GetVars()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &runState{
+		conns:      connections.NewPool(),
+		facts:      facts.NewCache(),
+		vars:       vars,
+		results:    make(map[string]map[string]interface{}),
+		registered: make(map[string]map[string]interface{}),
+		failed:     make(map[string]bool),
+	}
+
+	return state, nil
+}
+
+// setResult records the result of a step on a host so later steps
+// can refer to it. If the step has a register name, the result is
+// also saved under that name.
+func (r *runState) setResult(host *yakfile.Host, step yakfile.Step, result stepResult) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	key := hostKey(host)
+	if _, ok := r.results[key]; !ok {
+		r.results[key] = make(map[string]interface{})
+		r.registered[key] = make(map[string]interface{})
+	}
+
+	r.results[key][step.Name] = result.data()
+
+	if step.Register != "" {
+		r.registered[key][step.Register] = result.data()
+	}
+}
+
+// getResults returns the results of all steps which have been run
+// on a host along with the results which were registered.
+func (r *runState) getResults(host *yakfile.Host) (map[string]interface{}, map[string]interface{}) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	key := hostKey(host)
+
+	results := make(map[string]interface{})
+	for name, result := range r.results[key] {
+		results[name] = result
+	}
+
+	registered := make(map[string]interface{})
+	for name, result := range r.registered[key] {
+		registered[name] = result
+	}
+
+	return results, registered
+}
+
+// setFailed marks a host as failed. A failed host is not
+// included in the remaining steps of a run.
+func (r *runState) setFailed(host *yakfile.Host) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.failed[hostKey(host)] = true
+}
+
+// hasFailed determines if a host has failed.
+func (r *runState) hasFailed(host *yakfile.Host) bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return r.failed[hostKey(host)]
+}
+
+// failedCount returns the amount of hosts which have failed.
+func (r *runState) failedCount() int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return len(r.failed)
+}
+
+// stepResult represents the result of a step on a host.
+type stepResult struct {
+	actions.StepResult
+
+	skipped     bool
+	unreachable bool
+	err         error
+	duration    time.Duration
+
+	// item is the item of an iteration of a loop.
+	item interface{}
+
+	// loop holds the result of each iteration when the step has a
+	// loop. The step has changed if any iteration changed, has
+	// failed if any iteration failed, and is skipped if all
+	// iterations were skipped.
+	loop []stepResult
+}
+
+// iterations returns the result of each iteration of a loop or the
+// result itself if the step does not have a loop.
+func (r stepResult) iterations() []stepResult {
+	if len(r.loop) == 0 {
+		return []stepResult{r}
+	}
+
+	return r.loop
+}
+
+// data returns the result for use in the templated input and when
+// condition of later steps.
+func (r stepResult) data() map[string]interface{} {
+	data := map[string]interface{}{
+		"changed":   r.Changed,
+		"exit_code": r.ExitCode,
+		"failed":    r.err != nil,
+		"skipped":   r.skipped,
+		"stderr":    r.Stderr,
+		"stdout":    r.Stdout,
+	}
+
+	if r.loop != nil {
+		var results []interface{}
+		for _, v := range r.loop {
+			result := v.data()
+			result["item"] = v.item
+			results = append(results, result)
+		}
+		data["results"] = results
+	}
+
+	return data
+}
+
 // runStep is a convenience function to run a step or notify.
-func runStep(ctx context.Context, host yakfile.Host, step yakfile.Step) (bool, error) {
+// The step's when condition is evaluated and its input is rendered
+// with the run's vars, the host's vars and facts, and the results of
+// earlier steps before the step is run. If the step has a loop, it
+// is run once for each item. The result of the step is saved for
+// later steps.
+func runStep(ctx context.Context, state *runState, host yakfile.Host, step yakfile.Step) (result stepResult) {
 	log := ctx.Value("log").(*logrus.Logger)
 
 	log.Debugf("attempting to connect to %s via %s",
 		host.Name, host.ConnectionType)
 
-	if err := host.Connection.Connect(); err != nil {
-		return false, err
+	// Each host is only connected to once per run. The connection
+	// is reused by all steps and notifiers.
+	conn, err := state.conns.Get(hostKey(&host), host.Connection)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"host": host.Name,
+		}).Error(err)
+		result.err = err
+		result.unreachable = true
+		return
 	}
+	host.Connection = conn
+
+	defer func() {
+		state.setResult(&host, step, result)
+	}()
 
 	l := log.WithFields(logrus.Fields{
 		"host": host.Name,
 	})
-	ctx = context.WithValue(context.Background(), "log", l)
-	changed, err := actions.RunStep(ctx, host.Connection, step)
+
+	// Facts are only gathered once per host.
+	hostFacts, err := state.facts.Get(hostKey(&host), host.Connection)
+	if err != nil {
+		l.Warnf("unable to gather facts: %s", err)
+		hostFacts = &facts.Facts{}
+	}
+
+	ctx = context.WithValue(ctx, "facts", hostFacts)
+
+	if state.stream != nil {
+		ctx = context.WithValue(ctx, "output", state.stream.writer(host.Name))
+	}
+	data := stepData(state, &host, hostFacts)
+
+	items, err := step.LoopItems(data)
+	if err != nil {
+		l.Error(err)
+		result.err = err
+		return
+	}
+
+	if items == nil {
+		return runStepItem(ctx, l, &host, step, data)
+	}
+
+	// An empty loop is skipped.
+	result.skipped = true
+	result.loop = []stepResult{}
+
+	for _, item := range items {
+		data["item"] = item
+		il := l.WithFields(logrus.Fields{
+			"item": item,
+		})
+
+		r := runStepItem(ctx, il, &host, step, data)
+		r.item = item
+		result.loop = append(result.loop, r)
+
+		result.duration += r.duration
+		if r.Changed {
+			result.Changed = true
+		}
+
+		if r.err != nil && result.err == nil {
+			result.err = r.err
+		}
+
+		if !r.skipped {
+			result.skipped = false
+		}
+	}
+
+	return
+}
+
+// runStepItem will evaluate the when condition of a step, render
+// its input, and run it once.
+func runStepItem(ctx context.Context, l *logrus.Entry, host *yakfile.Host, step yakfile.Step, data map[string]interface{}) (result stepResult) {
+	start := time.Now()
+	defer func() {
+		result.duration = time.Since(start)
+	}()
+
+	run, err := step.EvaluateWhen(data)
+	if err != nil {
+		l.Error(err)
+		result.err = err
+		return
+	}
+
+	if !run {
+		l.Infof("skipped: %s", step.When)
+		result.skipped = true
+		return
+	}
+
+	step, err = step.Render(data)
 	if err != nil {
-		log.Error(err)
+		l.Error(err)
+		result.err = err
+		return
 	}
 
-	return changed, err
+	ctx = context.WithValue(ctx, "log", l)
+	result.StepResult, result.err = actions.RunStep(ctx, host.Connection, step)
+	if result.err != nil {
+		l.Error(result.err)
+	}
+
+	return
+}
+
+// hostKey returns a key which uniquely identifies a host in a run.
+func hostKey(host *yakfile.Host) string {
+	return fmt.Sprintf("%s/%s", host.ConnectionName, host.Name)
+}
+
+// stepData returns the data which is available to the templated
+// input, when condition, and loop of a step.
+func stepData(state *runState, host *yakfile.Host, hostFacts *facts.Facts) map[string]interface{} {
+	results, registered := state.getResults(host)
+
+	return map[string]interface{}{
+		"facts":      hostFacts.Data(),
+		"host":       host.Data(),
+		"registered": registered,
+		"steps":      results,
+		"vars":       state.vars,
+	}
 }
@@ -6,49 +6,146 @@ import (
 
 	"github.com/jtopjian/yak/lib/connections"
 	"github.com/jtopjian/yak/lib/yakfile"
+
+	"github.com/mitchellh/mapstructure"
 )
 
-func RunStep(ctx context.Context, conn connections.Connection, step yakfile.Step) (bool, error) {
+// StepResult represents the result of running a step.
+// Stdout, Stderr, and ExitCode are only set by actions which run
+// a command, such as exec.
+type StepResult struct {
+	Changed  bool
+	ExitCode int
+	Stderr   string
+	Stdout   string
+
+	// Resource, Name, and State describe what the step acted on.
+	// They are the same values which are logged by an action.
+	Resource string
+	Name     string
+	State    string
+}
+
+// RunStep will run the action of a step.
+func RunStep(ctx context.Context, conn connections.Connection, step yakfile.Step) (StepResult, error) {
+	result, err := runAction(ctx, conn, step)
+	result.Resource, result.Name, result.State = describeStep(step)
+
+	return result, err
+}
+
+// runAction will run the action of a step based on its type.
+func runAction(ctx context.Context, conn connections.Connection, step yakfile.Step) (StepResult, error) {
 	action := step.Action
 
 	switch action {
 	// Core Actions
 	case "exec":
 		rr, err := Exec(ctx, conn, step)
+		if err != nil {
+			return StepResult{}, err
+		}
+
 		if rr.ExitCode != 0 {
 			err = fmt.Errorf(rr.Stderr)
+			if rr.Stderr == "" {
+				err = fmt.Errorf("command exited with %d", rr.ExitCode)
+			}
 		}
-		return rr.Applied, err
+
+		result := StepResult{
+			Changed:  rr.Applied,
+			ExitCode: rr.ExitCode,
+			Stderr:   rr.Stderr,
+			Stdout:   rr.Stdout,
+		}
+
+		return result, err
 
 	case "delete-file":
+		if checkMode(ctx) {
+			return changedResult(fileCheck(ctx, conn, step, "delete"))
+		}
+
 		fr, err := FileDelete(ctx, conn, step)
-		return fr.Applied, err
+		if err != nil {
+			return StepResult{}, err
+		}
+
+		return changedResult(fr.Applied, nil)
 
 	case "download-file":
+		if checkMode(ctx) {
+			return changedResult(fileCheck(ctx, conn, step, "download"))
+		}
+
 		fr, err := FileDownload(ctx, conn, step)
-		return fr.Applied, err
+		if err != nil {
+			return StepResult{}, err
+		}
+
+		return changedResult(fr.Applied, nil)
 
 	case "upload-file":
+		if checkMode(ctx) {
+			return changedResult(fileCheck(ctx, conn, step, "upload"))
+		}
+
 		fr, err := FileUpload(ctx, conn, step)
-		return fr.Applied, err
+		if err != nil {
+			return StepResult{}, err
+		}
+
+		return changedResult(fr.Applied, nil)
 
 	// Compound Actions
 	case "apt.key":
-		return AptKeyAction(ctx, conn, step)
+		return changedResult(AptKeyAction(ctx, conn, step))
 
 	case "apt.ppa":
-		return AptPPAAction(ctx, conn, step)
+		return changedResult(AptPPAAction(ctx, conn, step))
 
 	case "apt.pkg":
-		return AptPkgAction(ctx, conn, step)
+		return changedResult(AptPkgAction(ctx, conn, step))
 
 	case "apt.source":
-		return AptSourceAction(ctx, conn, step)
+		return changedResult(AptSourceAction(ctx, conn, step))
 
 	case "cron.entry":
-		return CronEntryAction(ctx, conn, step)
+		return changedResult(CronEntryAction(ctx, conn, step))
+
+	default:
+		return StepResult{}, fmt.Errorf("action %s not supported", action)
+	}
+}
+
+// changedResult is a convenience function to build a StepResult
+// for actions which only report if a change was made.
+func changedResult(changed bool, err error) (StepResult, error) {
+	return StepResult{Changed: changed}, err
+}
 
+// describeStep returns the resource, name, and state of a step.
+func describeStep(step yakfile.Step) (resource, name, state string) {
+	resource = step.Action
+
+	switch step.Action {
+	case "exec":
+		name, _ = step.Input["cmd"].(string)
+	case "delete-file":
+		name, _ = step.Input["path"].(string)
+	case "download-file", "upload-file":
+		name, _ = step.Input["destination"].(string)
 	default:
-		return false, fmt.Errorf("action %s not supported", action)
+		var fields BaseFields
+		mapstructure.Decode(step.Input, &fields)
+
+		name = fields.Name
+		state = fields.State
+		if state == "" {
+			state = "present"
+		}
 	}
+
+	return
 }
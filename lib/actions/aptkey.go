@@ -67,8 +67,13 @@ func AptKeyAction(
 
 	if key.State == "absent" {
 		if exists {
-			err = key.Delete()
 			change = true
+			if checkMode(ctx) {
+				key.logInfo("would be deleted")
+				return
+			}
+
+			err = key.Delete()
 			return
 		}
 
@@ -76,8 +81,13 @@ func AptKeyAction(
 	}
 
 	if !exists {
-		err = key.Create()
 		change = true
+		if checkMode(ctx) {
+			key.logInfo("would be created")
+			return
+		}
+
+		err = key.Create()
 		return
 	}
 
@@ -87,9 +97,10 @@ func AptKeyAction(
 // Exists will determine if an apt.key exists.
 func (r AptKey) Exists() (bool, error) {
 	eo := ExecOptions{
-		Command: fmt.Sprintf("apt-key export %s", r.Name),
-		Sudo:    r.Sudo,
-		Timeout: r.Timeout,
+		Command:       utils.ShellJoin("apt-key", "export", r.Name),
+		Sudo:          r.Sudo,
+		BecomeOptions: r.BecomeOptions,
+		Timeout:       r.Timeout,
 	}
 
 	r.logDebug("checking if installed")
@@ -121,8 +132,9 @@ func (r AptKey) Create() error {
 	var cfo CopyFileOptions
 
 	eo := ExecOptions{
-		Sudo:    r.Sudo,
-		Timeout: r.Timeout,
+		Sudo:          r.Sudo,
+		BecomeOptions: r.BecomeOptions,
+		Timeout:       r.Timeout,
 	}
 
 	r.logInfo("adding")
@@ -153,7 +165,7 @@ func (r AptKey) Create() error {
 			return err
 		}
 
-		eo.Command = fmt.Sprintf("apt-key add %s", tmpfile.Name())
+		eo.Command = utils.ShellJoin("apt-key", "add", tmpfile.Name())
 		r.logDebug("running command: %s", eo.Command)
 		rr, err := exec(r.ctx, r.conn, eo)
 		if err != nil {
@@ -179,8 +191,8 @@ func (r AptKey) Create() error {
 	}
 
 	if r.KeyServer != "" {
-		eo.Command = fmt.Sprintf("apt-key adv --keyserver %s --recv-keys %s",
-			r.KeyServer, r.Name)
+		eo.Command = utils.ShellJoin("apt-key", "adv", "--keyserver", r.KeyServer,
+			"--recv-keys", r.Name)
 
 		r.logDebug("running command: %s", eo.Command)
 		rr, err := exec(r.ctx, r.conn, eo)
@@ -200,12 +212,13 @@ func (r AptKey) Create() error {
 // Delete deletes a key managed by apt.key.
 func (r AptKey) Delete() error {
 	eo := ExecOptions{
-		Sudo:    r.Sudo,
-		Timeout: r.Timeout,
+		Sudo:          r.Sudo,
+		BecomeOptions: r.BecomeOptions,
+		Timeout:       r.Timeout,
 	}
 
 	r.logInfo("deleting")
-	eo.Command = fmt.Sprintf("apt-key del %s", r.Name)
+	eo.Command = utils.ShellJoin("apt-key", "del", r.Name)
 	r.logDebug("running command: %s", eo.Command)
 	rr, err := exec(r.ctx, r.conn, eo)
 	if err != nil {
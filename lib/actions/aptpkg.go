@@ -46,8 +46,13 @@ func AptPkgAction(
 
 	if pkg.State == "absent" {
 		if exists {
-			err = pkg.Delete()
 			change = true
+			if checkMode(ctx) {
+				pkg.logInfo("would be deleted")
+				return
+			}
+
+			err = pkg.Delete()
 			return
 		}
 
@@ -55,8 +60,13 @@ func AptPkgAction(
 	}
 
 	if !exists || pkg.State == "latest" {
-		err = pkg.Create()
 		change = true
+		if checkMode(ctx) {
+			pkg.logInfo("would be created")
+			return
+		}
+
+		err = pkg.Create()
 		return
 	}
 
@@ -66,9 +76,10 @@ func AptPkgAction(
 // Exists will determine if an apt.pkg exists.
 func (r AptPkg) Exists() (bool, error) {
 	eo := ExecOptions{
-		Command: fmt.Sprintf("apt-cache policy %s", r.Name),
-		Sudo:    r.Sudo,
-		Timeout: r.Timeout,
+		Command:       utils.ShellJoin("apt-cache", "policy", r.Name),
+		Sudo:          r.Sudo,
+		BecomeOptions: r.BecomeOptions,
+		Timeout:       r.Timeout,
 	}
 
 	r.logDebug("checking if installed")
@@ -110,8 +121,9 @@ func (r AptPkg) Exists() (bool, error) {
 
 func (r AptPkg) Create() error {
 	eo := ExecOptions{
-		Sudo:    r.Sudo,
-		Timeout: r.Timeout,
+		Sudo:          r.Sudo,
+		BecomeOptions: r.BecomeOptions,
+		Timeout:       r.Timeout,
 	}
 
 	eo.Env = []string{
@@ -127,9 +139,9 @@ func (r AptPkg) Create() error {
 		createArgs = r.Name
 	}
 
-	eo.Command = fmt.Sprintf(
-		"apt-get install -y --allow-downgrades --allow-remove-essential "+
-			"--allow-change-held-packages -o DPkg::Options::=--force-confold %s",
+	eo.Command = utils.ShellJoin(
+		"apt-get", "install", "-y", "--allow-downgrades", "--allow-remove-essential",
+		"--allow-change-held-packages", "-o", "DPkg::Options::=--force-confold",
 		createArgs)
 
 	r.logInfo("installing")
@@ -147,8 +159,9 @@ func (r AptPkg) Create() error {
 
 func (r AptPkg) Delete() error {
 	eo := ExecOptions{
-		Sudo:    r.Sudo,
-		Timeout: r.Timeout,
+		Sudo:          r.Sudo,
+		BecomeOptions: r.BecomeOptions,
+		Timeout:       r.Timeout,
 	}
 
 	eo.Env = []string{
@@ -157,7 +170,7 @@ func (r AptPkg) Delete() error {
 		"APT_LISTCHANGES_FRONTEND=none",
 	}
 
-	eo.Command = fmt.Sprintf("apt-get purge -q -y %s", r.Name)
+	eo.Command = utils.ShellJoin("apt-get", "purge", "-q", "-y", r.Name)
 
 	r.logInfo("removing")
 	r.logDebug("running command: %s", eo.Command)
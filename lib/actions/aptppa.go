@@ -51,8 +51,13 @@ func AptPPAAction(
 
 	if ppa.State == "absent" {
 		if exists {
-			err = ppa.Delete()
 			change = true
+			if checkMode(ctx) {
+				ppa.logInfo("would be deleted")
+				return
+			}
+
+			err = ppa.Delete()
 			return
 		}
 
@@ -60,8 +65,13 @@ func AptPPAAction(
 	}
 
 	if !exists {
-		err = ppa.Create()
 		change = true
+		if checkMode(ctx) {
+			ppa.logInfo("would be created")
+			return
+		}
+
+		err = ppa.Create()
 		return
 	}
 
@@ -81,9 +91,10 @@ func (r AptPPA) Exists() (bool, error) {
 	}
 
 	eo := ExecOptions{
-		Command: fmt.Sprintf(`stat "%s"`, r.fileName),
-		Sudo:    r.Sudo,
-		Timeout: r.Timeout,
+		Command:       utils.ShellJoin("stat", r.fileName),
+		Sudo:          r.Sudo,
+		BecomeOptions: r.BecomeOptions,
+		Timeout:       r.Timeout,
 	}
 
 	r.logDebug("checking if installed")
@@ -105,9 +116,10 @@ func (r AptPPA) Exists() (bool, error) {
 // Create will create a ppa.
 func (r AptPPA) Create() error {
 	eo := ExecOptions{
-		Command: fmt.Sprintf("apt-add-repository -y ppa:%s", r.Name),
-		Sudo:    r.Sudo,
-		Timeout: r.Timeout,
+		Command:       utils.ShellJoin("apt-add-repository", "-y", "ppa:"+r.Name),
+		Sudo:          r.Sudo,
+		BecomeOptions: r.BecomeOptions,
+		Timeout:       r.Timeout,
 	}
 
 	r.logInfo("adding")
@@ -148,9 +160,10 @@ func (r AptPPA) Delete() error {
 	}
 
 	eo := ExecOptions{
-		Command: fmt.Sprintf("apt-add-repository -y -r ppa:%s", r.Name),
-		Sudo:    r.Sudo,
-		Timeout: r.Timeout,
+		Command:       utils.ShellJoin("apt-add-repository", "-y", "-r", "ppa:"+r.Name),
+		Sudo:          r.Sudo,
+		BecomeOptions: r.BecomeOptions,
+		Timeout:       r.Timeout,
 	}
 
 	r.logInfo("deleting")
@@ -166,7 +179,7 @@ func (r AptPPA) Delete() error {
 		return fmt.Errorf("unable to delete apt.ppa %s: %s", r.Name, err)
 	}
 
-	eo.Command = fmt.Sprintf("rm %s", r.fileName)
+	eo.Command = utils.ShellJoin("rm", r.fileName)
 	r.logDebug("running command: %s", eo.Command)
 	rr, err = exec(r.ctx, r.conn, eo)
 	if err != nil {
@@ -54,8 +54,13 @@ func AptSourceAction(
 
 	if as.State == "absent" {
 		if exists {
-			err = as.Delete()
 			change = true
+			if checkMode(ctx) {
+				as.logInfo("would be deleted")
+				return
+			}
+
+			err = as.Delete()
 			return
 		}
 
@@ -63,8 +68,13 @@ func AptSourceAction(
 	}
 
 	if !exists {
-		err = as.Create()
 		change = true
+		if checkMode(ctx) {
+			as.logInfo("would be created")
+			return
+		}
+
+		err = as.Create()
 		return
 	}
 
@@ -80,9 +90,10 @@ func (r AptSource) Exists() (bool, error) {
 	r.logDebug("checking if %s exists", path)
 
 	eo := ExecOptions{
-		Command: fmt.Sprintf(`cat "%s"`, path),
-		Sudo:    r.Sudo,
-		Timeout: r.Timeout,
+		Command:       utils.ShellJoin("cat", path),
+		Sudo:          r.Sudo,
+		BecomeOptions: r.BecomeOptions,
+		Timeout:       r.Timeout,
 	}
 
 	r.logDebug("running command: %s", eo.Command)
@@ -156,8 +167,9 @@ func (r AptSource) Create() error {
 	}
 
 	eo := ExecOptions{
-		Sudo:    r.Sudo,
-		Timeout: r.Timeout,
+		Sudo:          r.Sudo,
+		BecomeOptions: r.BecomeOptions,
+		Timeout:       r.Timeout,
 	}
 
 	if _, err := fileUploadAndMove(r.ctx, r.conn, cfo, eo, path); err != nil {
@@ -180,9 +192,10 @@ func (r AptSource) Create() error {
 func (r AptSource) Delete() error {
 	path := fmt.Sprintf("/etc/apt/sources.list.d/%s.list", r.Name)
 	eo := ExecOptions{
-		Command: fmt.Sprintf(`rm "%s"`, path),
-		Sudo:    r.Sudo,
-		Timeout: r.Timeout,
+		Command:       utils.ShellJoin("rm", path),
+		Sudo:          r.Sudo,
+		BecomeOptions: r.BecomeOptions,
+		Timeout:       r.Timeout,
 	}
 
 	r.logInfo("deleting")
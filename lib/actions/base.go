@@ -27,6 +27,9 @@ type BaseFields struct {
 	// Timeout is a timeout for the command.
 	Timeout int `mapstructure:"timeout"`
 
+	// BecomeOptions are options to run commands as another user.
+	BecomeOptions `mapstructure:",squash"`
+
 	// conn is an internal field to hold a connection.
 	conn connections.Connection
 
@@ -34,6 +37,16 @@ type BaseFields struct {
 	ContextLogger
 }
 
+// checkMode determines if a step is being run in check mode.
+// In check mode, actions only report if a change would be made.
+func checkMode(ctx context.Context) bool {
+	if check, ok := ctx.Value("check").(bool); ok {
+		return check
+	}
+
+	return false
+}
+
 // ContextLogger represents fields and methods to help with logging.
 type ContextLogger struct {
 	ctx context.Context
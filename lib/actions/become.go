@@ -0,0 +1,253 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jtopjian/yak/lib/config"
+	"github.com/jtopjian/yak/lib/connections"
+	"github.com/jtopjian/yak/lib/utils"
+	"github.com/jtopjian/yak/lib/yakfile"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	BecomeMethodDoas = "doas"
+	BecomeMethodSu   = "su"
+	BecomeMethodSudo = "sudo"
+
+	BecomeDefaultMethod = BecomeMethodSudo
+	BecomeDefaultUser   = "root"
+
+	// becomeShell is the shell which runs a command as another user.
+	becomeShell = "/bin/sh"
+
+	// becomeMask replaces the become password in command output.
+	becomeMask = "********"
+
+	// suPrompt is written to stderr by su before it reads a password
+	// from stdin.
+	suPrompt = "Password: "
+)
+
+// BecomeOptions represents options to run commands as another user.
+type BecomeOptions struct {
+	// Become is if the command should be run as another user.
+	Become bool `mapstructure:"become"`
+
+	// BecomeAuth is an auth entry in yak.cfg with the password
+	// which is needed to become another user.
+	BecomeAuth string `mapstructure:"become_auth"`
+
+	// BecomeMethod is how to become another user: sudo, su, or doas.
+	BecomeMethod string `mapstructure:"become_method"`
+
+	// BecomeUser is the user to become.
+	BecomeUser string `mapstructure:"become_user"`
+}
+
+// becomeAuth represents the options of a become auth entry.
+type becomeAuth struct {
+	Password string `mapstructure:"password"`
+}
+
+// becomeCommand is a command which is run as another user.
+type becomeCommand struct {
+	command  string
+	method   string
+	password string
+}
+
+// newBecomeCommand will wrap a command so it is run as another user.
+// The command is run by a shell as a single quoted argument, so
+// compound commands run entirely as the other user.
+func newBecomeCommand(bo BecomeOptions, cmd string) (*becomeCommand, error) {
+	method := bo.BecomeMethod
+	if method == "" {
+		method = BecomeDefaultMethod
+	}
+
+	user := bo.BecomeUser
+	if user == "" {
+		user = BecomeDefaultUser
+	}
+
+	password, err := bo.password()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yakfile.ValidateBecome(method, password != ""); err != nil {
+		return nil, err
+	}
+
+	user = utils.ShellQuote(user)
+	cmd = utils.ShellQuote(cmd)
+
+	var command string
+	switch method {
+	case BecomeMethodSudo:
+		// Without a password, sudo fails instead of waiting for one.
+		// With a password, cached credentials are ignored so sudo
+		// always reads the password instead of the command.
+		if password != "" {
+			command = fmt.Sprintf("sudo -k -H -S -p '' -u %s -- %s -c %s", user, becomeShell, cmd)
+		} else {
+			command = fmt.Sprintf("sudo -H -n -u %s -- %s -c %s", user, becomeShell, cmd)
+		}
+	case BecomeMethodSu:
+		// su reads the password from stdin when it isn't run from a
+		// terminal.
+		command = fmt.Sprintf("su -s %s -c %s %s", becomeShell, cmd, user)
+	case BecomeMethodDoas:
+		command = fmt.Sprintf("doas -n -u %s %s -c %s", user, becomeShell, cmd)
+	}
+
+	bc := &becomeCommand{
+		command:  command,
+		method:   method,
+		password: password,
+	}
+
+	return bc, nil
+}
+
+// stdin returns the stdin of the command. The password, if there
+// is one, is sent through stdin so it never appears in the command
+// line or the logs.
+func (r becomeCommand) stdin() io.Reader {
+	if r.password == "" {
+		return nil
+	}
+
+	return strings.NewReader(r.password + "\n")
+}
+
+// mask will replace the password in the output of a command.
+func (r becomeCommand) mask(rr *connections.RunResult) {
+	if rr == nil || r.password == "" {
+		return
+	}
+
+	rr.Stdout = strings.Replace(rr.Stdout, r.password, becomeMask, -1)
+	rr.Stderr = strings.Replace(rr.Stderr, r.password, becomeMask, -1)
+
+	if r.method == BecomeMethodSu {
+		rr.Stderr = strings.TrimPrefix(rr.Stderr, suPrompt)
+	}
+}
+
+// maskWriter returns a writer which replaces the password in the
+// output of a command before writing it to w.
+func (r becomeCommand) maskWriter(w io.Writer) io.Writer {
+	if r.password == "" {
+		return w
+	}
+
+	return &maskWriter{
+		w:        w,
+		password: []byte(r.password),
+	}
+}
+
+// maskWriter is a writer which masks a password. Output is written
+// in chunks as it is read, so a password can be split between
+// writes. The end of a write which could be the start of the
+// password is held until the next write or Flush.
+type maskWriter struct {
+	w        io.Writer
+	password []byte
+	pending  []byte
+}
+
+// Write implements io.Writer.
+func (r *maskWriter) Write(p []byte) (int, error) {
+	buf := append(r.pending, p...)
+	r.pending = nil
+
+	var out []byte
+	for {
+		i := bytes.Index(buf, r.password)
+		if i < 0 {
+			break
+		}
+
+		out = append(out, buf[:i]...)
+		out = append(out, becomeMask...)
+		buf = buf[i+len(r.password):]
+	}
+
+	// Hold the longest end of buf which is the start of the password.
+	held := len(r.password) - 1
+	if held > len(buf) {
+		held = len(buf)
+	}
+
+	for ; held > 0; held-- {
+		if bytes.HasSuffix(buf, r.password[:held]) {
+			break
+		}
+	}
+
+	out = append(out, buf[:len(buf)-held]...)
+	r.pending = append([]byte(nil), buf[len(buf)-held:]...)
+
+	if len(out) > 0 {
+		if _, err := r.w.Write(out); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush will write any output which was held and flush w.
+func (r *maskWriter) Flush() error {
+	if len(r.pending) > 0 {
+		pending := r.pending
+		r.pending = nil
+
+		if _, err := r.w.Write(pending); err != nil {
+			return err
+		}
+	}
+
+	return flushWriter(r.w)
+}
+
+// flushWriter will flush a writer if it holds output, such as the
+// writer of a streamed command.
+func flushWriter(w io.Writer) error {
+	if f, ok := w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+
+	return nil
+}
+
+// password will read the password from the become auth entry.
+func (r BecomeOptions) password() (string, error) {
+	if r.BecomeAuth == "" {
+		return "", nil
+	}
+
+	yakConf, err := config.FindAndLoad()
+	if err != nil {
+		return "", err
+	}
+
+	entry, err := yakConf.GetAuthEntry(r.BecomeAuth)
+	if err != nil {
+		return "", err
+	}
+
+	var auth becomeAuth
+	if err := mapstructure.Decode(entry.Options, &auth); err != nil {
+		return "", err
+	}
+
+	return auth.Password, nil
+}
@@ -78,8 +78,13 @@ func CronEntryAction(
 
 	if ce.State == "absent" {
 		if exists {
-			err = ce.Delete()
 			change = true
+			if checkMode(ctx) {
+				ce.logInfo("would be deleted")
+				return
+			}
+
+			err = ce.Delete()
 			return
 		}
 
@@ -87,8 +92,13 @@ func CronEntryAction(
 	}
 
 	if !exists {
-		err = ce.Create()
 		change = true
+		if checkMode(ctx) {
+			ce.logInfo("would be created")
+			return
+		}
+
+		err = ce.Create()
 		return
 	}
 
@@ -198,9 +208,10 @@ func (r CronEntry) entry() string {
 // getEntries returns the cron entries from a remote host.
 func (r CronEntry) getEntries() ([]string, error, error) {
 	eo := ExecOptions{
-		Command: fmt.Sprintf("crontab -u %s -l", r.User),
-		Sudo:    r.Sudo,
-		Timeout: r.Timeout,
+		Command:       utils.ShellJoin("crontab", "-u", r.User, "-l"),
+		Sudo:          r.Sudo,
+		BecomeOptions: r.BecomeOptions,
+		Timeout:       r.Timeout,
 	}
 
 	r.logDebug("running command: %s", eo.Command)
@@ -243,9 +254,10 @@ func (r CronEntry) pushEntries(entries []string) error {
 	}
 
 	eo := ExecOptions{
-		Command: fmt.Sprintf(`crontab -u %s %s`, r.User, tmpfile.Name()),
-		Sudo:    r.Sudo,
-		Timeout: r.Timeout,
+		Command:       utils.ShellJoin("crontab", "-u", r.User, tmpfile.Name()),
+		Sudo:          r.Sudo,
+		BecomeOptions: r.BecomeOptions,
+		Timeout:       r.Timeout,
 	}
 
 	r.logDebug("running command: %s", eo.Command)
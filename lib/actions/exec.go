@@ -3,8 +3,11 @@ package actions
 import (
 	"context"
 	"fmt"
+	"io"
+	"strings"
 
 	"github.com/jtopjian/yak/lib/connections"
+	"github.com/jtopjian/yak/lib/utils"
 	"github.com/jtopjian/yak/lib/yakfile"
 
 	"github.com/mitchellh/mapstructure"
@@ -17,10 +20,12 @@ type ExecOptions struct {
 	Command string   `mapstructure:"cmd"`
 	Dir     string   `mapstructure:"dir"`
 	Env     []string `mapstructure:"env"`
+	Stdin   string   `mapstructure:"stdin"`
 	Sudo    bool     `mapstructure:"sudo"`
 	Timeout int      `mapstructure:"timeout"`
 	Unless  string   `mapstructure:"unless"`
 
+	BecomeOptions `mapstructure:",squash"`
 	ContextLogger
 }
 
@@ -50,50 +55,71 @@ func Exec(ctx context.Context, conn connections.Connection, step yakfile.Step) (
 		internal = true
 	}
 
-	cmd := eo.Command
-	unless := eo.Unless
-
+	// sudo is the same as become with the default method and user.
 	if eo.Sudo {
-		cmd = fmt.Sprintf(`sudo %s`, cmd)
-		unless = fmt.Sprintf(`sudo %s`, unless)
-	}
-
-	if eo.Dir != "" {
-		cmd = fmt.Sprintf(`cd %s && %s`, eo.Dir, cmd)
-		unless = fmt.Sprintf(`cd %s && %s`, eo.Dir, unless)
+		eo.Become = true
 	}
 
-	for _, env := range eo.Env {
-		cmd = fmt.Sprintf(`%s && %s`, env, cmd)
-		unless = fmt.Sprintf(`%s && %s`, env, unless)
+	ro, becomeCmd, err := eo.runOptions(eo.Command, eo.Stdin)
+	if err != nil {
+		return nil, err
 	}
+	cmd := ro.Command
 
-	ro := connections.RunOptions{
-		Command: cmd,
-		Timeout: eo.Timeout,
+	// When streaming, the output of the command is written as it
+	// is produced.
+	if w, ok := ctx.Value("output").(io.Writer); ok {
+		if becomeCmd != nil {
+			w = becomeCmd.maskWriter(w)
+		}
+		ro.Log = &w
 	}
 
 	if eo.Unless != "" {
-		if !internal {
-			eo.logInfo(fmt.Sprintf("running unless command: %s", unless))
+		uo, becomeUnless, err := eo.runOptions(eo.Unless, "")
+		if err != nil {
+			return nil, err
 		}
-		uo := connections.RunOptions{
-			Command: unless,
-			Timeout: eo.Timeout,
+
+		if !internal {
+			eo.logInfo(fmt.Sprintf("running unless command: %s", uo.Command))
 		}
 
 		ur, err := conn.RunCommand(uo)
+		if becomeUnless != nil {
+			becomeUnless.mask(ur)
+		}
+
 		if ur.ExitCode == 0 {
 			ur.Applied = false
 			return ur, err
 		}
 	}
 
+	// In check mode, the command is never run. It's assumed
+	// the command would make a change.
+	if checkMode(ctx) && !internal {
+		eo.logInfo(fmt.Sprintf("would run command: %s", cmd))
+		return &connections.RunResult{Applied: true}, nil
+	}
+
 	if !internal {
 		eo.logInfo(fmt.Sprintf("running command: %s", cmd))
 	}
 
-	return conn.RunCommand(ro)
+	rr, err := conn.RunCommand(ro)
+
+	// A streamed line without a newline is written once the command
+	// has finished.
+	if ro.Log != nil {
+		flushWriter(*ro.Log)
+	}
+
+	if becomeCmd != nil {
+		becomeCmd.mask(rr)
+	}
+
+	return rr, err
 }
 
 // exec will execute an arbitrary command.
@@ -104,15 +130,87 @@ func exec(ctx context.Context, conn connections.Connection, eo ExecOptions) (*co
 		Action: "exec",
 		Name:   "exec",
 		Input: map[string]interface{}{
-			"cmd":       eo.Command,
-			"sudo":      eo.Sudo,
-			"timeout":   eo.Timeout,
-			"dir":       eo.Dir,
-			"env":       eo.Env,
-			"unless":    eo.Unless,
-			"_internal": true,
+			"cmd":           eo.Command,
+			"stdin":         eo.Stdin,
+			"sudo":          eo.Sudo,
+			"become":        eo.Become,
+			"become_auth":   eo.BecomeAuth,
+			"become_method": eo.BecomeMethod,
+			"become_user":   eo.BecomeUser,
+			"timeout":       eo.Timeout,
+			"dir":           eo.Dir,
+			"env":           eo.Env,
+			"unless":        eo.Unless,
+			"_internal":     true,
 		},
 	}
 
 	return Exec(ctx, conn, step)
 }
+
+// env returns the environment variables of an exec. Each variable
+// is a key=val pair.
+func (r ExecOptions) env() (map[string]string, error) {
+	env := make(map[string]string)
+	for _, v := range r.Env {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || !utils.ValidEnvName(parts[0]) {
+			return nil, fmt.Errorf("invalid env %s: must be key=val", v)
+		}
+
+		env[parts[0]] = parts[1]
+	}
+
+	return env, nil
+}
+
+// runOptions returns the options to run a command of an exec. The
+// stdin is sent to the command. If the command is run as another
+// user, the environment and directory are set by the shell of that
+// user since sudo and su reset them.
+func (r ExecOptions) runOptions(cmd, stdin string) (connections.RunOptions, *becomeCommand, error) {
+	env, err := r.env()
+	if err != nil {
+		return connections.RunOptions{}, nil, err
+	}
+
+	ro := connections.RunOptions{
+		Command: cmd,
+		Dir:     r.Dir,
+		Env:     env,
+		Timeout: r.Timeout,
+	}
+
+	if stdin != "" {
+		ro.Stdin = strings.NewReader(stdin)
+	}
+
+	if !r.Become {
+		return ro, nil, nil
+	}
+
+	script, err := utils.ShellScript(cmd, r.Dir, env)
+	if err != nil {
+		return connections.RunOptions{}, nil, err
+	}
+
+	bc, err := newBecomeCommand(r.BecomeOptions, script)
+	if err != nil {
+		return connections.RunOptions{}, nil, err
+	}
+
+	ro.Command = bc.command
+	ro.Dir = ""
+	ro.Env = nil
+
+	// The password is read before the command reads its stdin.
+	if password := bc.stdin(); password != nil {
+		if ro.Stdin != nil {
+			ro.Stdin = io.MultiReader(password, ro.Stdin)
+		} else {
+			ro.Stdin = password
+		}
+	}
+
+	return ro, bc, nil
+}
@@ -2,10 +2,16 @@ package actions
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/jtopjian/yak/lib/connections"
+	"github.com/jtopjian/yak/lib/utils"
 	"github.com/jtopjian/yak/lib/yakfile"
 
 	"github.com/mitchellh/mapstructure"
@@ -152,7 +158,7 @@ func fileUploadAndMove(
 		return nil, fmt.Errorf("unable to upload file to %s", cfo.Destination)
 	}
 
-	eo.Command = fmt.Sprintf(`mv "%s" "%s"`, cfo.Destination, finalDestination)
+	eo.Command = utils.ShellJoin("mv", cfo.Destination, finalDestination)
 	rr, err := exec(ctx, conn, eo)
 	if err != nil {
 		return nil, err
@@ -309,3 +315,170 @@ func fileDelete(
 
 	return FileDelete(ctx, conn, step)
 }
+
+// fileCheck will determine if a file action would make a change
+// without actually making the change. It is used in check mode.
+func fileCheck(
+	ctx context.Context,
+	conn connections.Connection,
+	step yakfile.Step,
+	action string,
+) (bool, error) {
+
+	var cl ContextLogger
+	if log, ok := ctx.Value("log").(*logrus.Entry); ok {
+		log = log.WithFields(logrus.Fields{
+			"action": fmt.Sprintf("file-%s", action),
+		})
+		cl.ctx = context.WithValue(ctx, "log", log)
+	}
+
+	switch action {
+	case "delete":
+		var fo FileOptions
+		if err := mapstructure.Decode(step.Input, &fo); err != nil {
+			return false, err
+		}
+
+		if fo.Path == "" {
+			return false, fmt.Errorf("path is required for file delete")
+		}
+
+		fr, err := conn.FileInfo(connections.FileOptions{
+			Path:    fo.Path,
+			Timeout: fo.Timeout,
+		})
+		if err != nil {
+			return false, err
+		}
+
+		if fr.Exists {
+			cl.logInfo(fmt.Sprintf("would delete %s", fo.Path))
+			return true, nil
+		}
+
+		return false, nil
+
+	case "upload", "download":
+		var cfo CopyFileOptions
+		if err := mapstructure.Decode(step.Input, &cfo); err != nil {
+			return false, err
+		}
+
+		if cfo.Source == "" {
+			return false, fmt.Errorf("source is required for file %ss", action)
+		}
+
+		if cfo.Destination == "" {
+			return false, fmt.Errorf("destination is required for file %ss", action)
+		}
+
+		// The local side of the copy is checked directly
+		// while the remote side is checked through the connection.
+		localPath, remotePath := cfo.Source, cfo.Destination
+		if action == "download" {
+			localPath, remotePath = cfo.Destination, cfo.Source
+		}
+
+		fr, err := conn.FileInfo(connections.FileOptions{
+			Path:    remotePath,
+			Timeout: cfo.Timeout,
+		})
+		if err != nil {
+			return false, err
+		}
+
+		stat, err := os.Stat(localPath)
+		if err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+
+		switch action {
+		case "upload":
+			if stat == nil {
+				return false, fmt.Errorf("source %s does not exist", localPath)
+			}
+
+			if !fr.Exists {
+				cl.logInfo(fmt.Sprintf("would upload %s to %s", cfo.Source, cfo.Destination))
+				return true, nil
+			}
+
+			same, err := sameFileChecksum(conn, localPath, remotePath, cfo.Timeout)
+			if err != nil {
+				return false, err
+			}
+
+			// Modes are compared as the octal digits reported by
+			// FileInfo. The owner is only set, and so only compared,
+			// if one was given.
+			mode := os.FileMode(cfo.Mode)
+			if mode == 0 {
+				mode = os.FileMode(0640)
+			}
+			expectedMode, _ := strconv.Atoi(fmt.Sprintf("%o", mode.Perm()))
+
+			owner := cfo.UID != 0 || cfo.GID != 0
+			ownerChanged := owner && (fr.FileInfo.UID != cfo.UID || fr.FileInfo.GID != cfo.GID)
+
+			if !same || fr.FileInfo.Mode != expectedMode || ownerChanged {
+				cl.logInfo(fmt.Sprintf("would upload %s to %s", cfo.Source, cfo.Destination))
+				return true, nil
+			}
+		case "download":
+			if !fr.Exists {
+				return false, fmt.Errorf("source %s does not exist", remotePath)
+			}
+
+			if stat == nil {
+				cl.logInfo(fmt.Sprintf("would download %s to %s", cfo.Source, cfo.Destination))
+				return true, nil
+			}
+
+			same, err := sameFileChecksum(conn, localPath, remotePath, cfo.Timeout)
+			if err != nil {
+				return false, err
+			}
+
+			if !same {
+				cl.logInfo(fmt.Sprintf("would download %s to %s", cfo.Source, cfo.Destination))
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	return false, fmt.Errorf("unsupported file action: %s", action)
+}
+
+// sameFileChecksum determines if a local file and a file on a host
+// have the same SHA-256 checksum. The checksum of the file on the host
+// is calculated by sha256sum.
+func sameFileChecksum(conn connections.Connection, localPath, remotePath string, timeout int) (bool, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false, fmt.Errorf("unable to checksum %s: %s", localPath, err)
+	}
+
+	rr, err := conn.RunCommand(connections.RunOptions{
+		Args:    []string{"sha256sum", remotePath},
+		Timeout: timeout,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	fields := strings.Fields(rr.Stdout)
+	if rr.ExitCode != 0 || len(fields) == 0 {
+		return false, fmt.Errorf("unable to checksum %s: %s", remotePath, rr.Stderr)
+	}
+
+	return fields[0] == hex.EncodeToString(h.Sum(nil)), nil
+}
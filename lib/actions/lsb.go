@@ -3,6 +3,9 @@ package actions
 import (
 	"fmt"
 	"regexp"
+	"strings"
+
+	"github.com/jtopjian/yak/lib/facts"
 )
 
 type LSBInfo struct {
@@ -12,18 +15,33 @@ type LSBInfo struct {
 	Codename       string
 }
 
+// GetLSBInfo returns the LSB information of a host. If the facts of
+// the host have already been gathered, they are used instead of
+// running lsb_release.
 func GetLSBInfo(b BaseFields) (*LSBInfo, error) {
 	var lsbInfo LSBInfo
 
+	if b.ctx != nil {
+		if f, ok := b.ctx.Value("facts").(*facts.Facts); ok && f.OS.Codename != "" {
+			lsbInfo.DistributionID = strings.Title(f.OS.ID)
+			lsbInfo.Description = f.OS.PrettyName
+			lsbInfo.Release = f.OS.VersionID
+			lsbInfo.Codename = f.OS.Codename
+
+			return &lsbInfo, nil
+		}
+	}
+
 	distributorRe := regexp.MustCompile("Distributor ID:\\s+(.+)\n")
 	descriptionRe := regexp.MustCompile("Description:\\s+(.+)\n")
 	releaseRe := regexp.MustCompile("Release:\\s+(.+)\n")
 	codenameRe := regexp.MustCompile("Codename:\\s+(.+)")
 
 	eo := ExecOptions{
-		Command: "/usr/bin/lsb_release -a",
-		Sudo:    b.Sudo,
-		Timeout: b.Timeout,
+		Command:       "/usr/bin/lsb_release -a",
+		Sudo:          b.Sudo,
+		BecomeOptions: b.BecomeOptions,
+		Timeout:       b.Timeout,
 	}
 
 	b.logDebug(fmt.Sprintf("running command: %s", eo.Command))
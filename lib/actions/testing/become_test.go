@@ -0,0 +1,169 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/jtopjian/yak/lib/actions"
+	"github.com/jtopjian/yak/lib/connections"
+	"github.com/jtopjian/yak/lib/yakfile"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testBecomeConnection is a connection which records the command and
+// stdin of the last command it ran. The output of the command is the
+// stdin, written to the log in chunks of chunkSize bytes, and stderr.
+type testBecomeConnection struct {
+	connections.Connection
+
+	chunkSize int
+	command   string
+	stderr    string
+	stdin     string
+}
+
+func (r *testBecomeConnection) RunCommand(ro connections.RunOptions) (*connections.RunResult, error) {
+	r.command = ro.Command
+	r.stdin = ""
+
+	if ro.Stdin != nil {
+		b, err := ioutil.ReadAll(ro.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		r.stdin = string(b)
+	}
+
+	if ro.Log != nil {
+		for i := 0; i < len(r.stdin); i += r.chunkSize {
+			end := i + r.chunkSize
+			if end > len(r.stdin) {
+				end = len(r.stdin)
+			}
+
+			io.WriteString(*ro.Log, r.stdin[i:end])
+		}
+	}
+
+	return &connections.RunResult{Stdout: r.stdin, Stderr: r.stderr, Applied: true}, nil
+}
+
+// testBecomeContext returns a context with a logger which discards
+// its output.
+func testBecomeContext() context.Context {
+	log := logrus.New()
+	log.Out = ioutil.Discard
+
+	return context.WithValue(context.Background(), "log", logrus.NewEntry(log))
+}
+
+func TestExec_Become(t *testing.T) {
+	t.Setenv("YAK_CONFIG_FILE", "fixtures/yak.cfg")
+
+	tests := []struct {
+		method   string
+		auth     string
+		command  string
+		stdin    string
+		expected string
+	}{
+		{"", "", `sudo -H -n -u 'root' -- /bin/sh -c 'id'`, "", ""},
+		{"sudo", "become-password", `sudo -k -H -S -p '' -u 'root' -- /bin/sh -c 'id'`, "s3cret\n", ""},
+		{"su", "", `su -s /bin/sh -c 'id' 'root'`, "", ""},
+		{"su", "become-password", `su -s /bin/sh -c 'id' 'root'`, "s3cret\n", ""},
+		{"doas", "", `doas -n -u 'root' /bin/sh -c 'id'`, "", ""},
+		{"doas", "become-password", "", "", "become_auth can't be used with become_method doas, doas only reads a password from a terminal"},
+		{"pbrun", "", "", "", "unsupported become_method: pbrun"},
+	}
+
+	for _, test := range tests {
+		conn := &testBecomeConnection{chunkSize: 1}
+		step := yakfile.Step{
+			Name:   "test",
+			Action: "exec",
+			Input: map[string]interface{}{
+				"cmd":           "id",
+				"become":        true,
+				"become_method": test.method,
+				"become_auth":   test.auth,
+			},
+		}
+
+		_, err := actions.RunStep(testBecomeContext(), conn, step)
+		if test.expected != "" {
+			if assert.Error(t, err, test.method) {
+				assert.Equal(t, test.expected, err.Error(), test.method)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%s: %s", test.method, err)
+		}
+
+		assert.Equal(t, test.command, conn.command, test.method)
+		assert.Equal(t, test.stdin, conn.stdin, test.method)
+	}
+}
+
+func TestExec_BecomeMask(t *testing.T) {
+	t.Setenv("YAK_CONFIG_FILE", "fixtures/yak.cfg")
+
+	// The password is masked even when it is split between writes.
+	for _, chunkSize := range []int{1, 2, 4, 100} {
+		var output bytes.Buffer
+		var w io.Writer = &output
+
+		conn := &testBecomeConnection{chunkSize: chunkSize}
+		step := yakfile.Step{
+			Name:   "test",
+			Action: "exec",
+			Input: map[string]interface{}{
+				"cmd":         "cat",
+				"stdin":       "a s3c s3cret s3",
+				"become":      true,
+				"become_auth": "become-password",
+			},
+		}
+
+		ctx := context.WithValue(testBecomeContext(), "output", w)
+		result, err := actions.RunStep(ctx, conn, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "********\na s3c ******** s3"
+		assert.Equal(t, expected, result.Stdout, chunkSize)
+		assert.Equal(t, expected, output.String(), chunkSize)
+	}
+}
+
+func TestExec_BecomeSuPrompt(t *testing.T) {
+	t.Setenv("YAK_CONFIG_FILE", "fixtures/yak.cfg")
+
+	// The prompt of su is removed from stderr.
+	conn := &testBecomeConnection{chunkSize: 100, stderr: "Password: su: Authentication failure\n"}
+	step := yakfile.Step{
+		Name:   "test",
+		Action: "exec",
+		Input: map[string]interface{}{
+			"cmd":           "id",
+			"become":        true,
+			"become_method": "su",
+			"become_auth":   "become-password",
+		},
+	}
+
+	result, err := actions.RunStep(testBecomeContext(), conn, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "su: Authentication failure\n", result.Stderr)
+}
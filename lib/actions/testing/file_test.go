@@ -0,0 +1,146 @@
+package testing
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jtopjian/yak/lib/actions"
+	"github.com/jtopjian/yak/lib/connections"
+	"github.com/jtopjian/yak/lib/yakfile"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileUpload_Check(t *testing.T) {
+	conn, err := connections.New("local", map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.txt")
+	destination := filepath.Join(dir, "destination.txt")
+
+	if err := ioutil.WriteFile(source, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	step := yakfile.Step{
+		Name:   "upload",
+		Action: "upload-file",
+		Input: map[string]interface{}{
+			"source":      source,
+			"destination": destination,
+			"mode":        0600,
+			"uid":         os.Getuid(),
+			"gid":         os.Getgid(),
+		},
+	}
+
+	check := context.WithValue(testBecomeContext(), "check", true)
+
+	// A missing file would be uploaded.
+	result, err := actions.RunStep(check, conn, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, result.Changed)
+
+	if _, err := actions.RunStep(testBecomeContext(), conn, step); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = actions.RunStep(check, conn, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, false, result.Changed)
+
+	// Content of the same size is compared by its checksum.
+	if err := ioutil.WriteFile(destination, []byte("jello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = actions.RunStep(check, conn, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, result.Changed)
+
+	// The mode is compared.
+	if _, err := actions.RunStep(testBecomeContext(), conn, step); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chmod(destination, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = actions.RunStep(check, conn, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, result.Changed)
+
+	// The owner is compared if one was given.
+	if _, err := actions.RunStep(testBecomeContext(), conn, step); err != nil {
+		t.Fatal(err)
+	}
+
+	step.Input["uid"] = os.Getuid() + 1
+
+	result, err = actions.RunStep(check, conn, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, result.Changed)
+}
+
+func TestFileDownload_Check(t *testing.T) {
+	conn, err := connections.New("local", map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.txt")
+	destination := filepath.Join(dir, "destination.txt")
+
+	if err := ioutil.WriteFile(source, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(destination, []byte("jello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	step := yakfile.Step{
+		Name:   "download",
+		Action: "download-file",
+		Input: map[string]interface{}{
+			"source":      source,
+			"destination": destination,
+		},
+	}
+
+	check := context.WithValue(testBecomeContext(), "check", true)
+
+	result, err := actions.RunStep(check, conn, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, result.Changed)
+
+	if err := ioutil.WriteFile(destination, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = actions.RunStep(check, conn, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, false, result.Changed)
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+
+	"github.com/jtopjian/yak/lib/utils"
 )
 
 // Connection is an interface which specifies what drivers
@@ -22,9 +24,39 @@ type Connection interface {
 
 // RunOptions represents options for running commands.
 type RunOptions struct {
+	// Command is a shell command line. It is run by the shell of
+	// the connection.
 	Command string
+
+	// Args is a command as a list of arguments. Each argument is
+	// passed to the command as is. Args is used when Command is
+	// not set.
+	Args []string
+
 	Timeout int
 	Log     *io.Writer
+
+	// Dir is the directory to run the command in.
+	Dir string
+
+	// Env are environment variables which are set for the command.
+	Env map[string]string
+
+	// Stdin is sent to the stdin of the command.
+	Stdin io.Reader
+}
+
+// command returns the command line of the options.
+func (r RunOptions) command() (string, error) {
+	if r.Command != "" {
+		return r.Command, nil
+	}
+
+	if len(r.Args) > 0 {
+		return utils.ShellJoin(r.Args...), nil
+	}
+
+	return "", fmt.Errorf("a command is required")
 }
 
 // RunResult respresents the result of an command execution.
@@ -81,6 +113,8 @@ func New(connType string, options map[string]interface{}) (Connection, error) {
 	}
 
 	switch connType {
+	case "docker":
+		return NewDocker(options)
 	case "local":
 		return NewLocal(options)
 	case "lxd":
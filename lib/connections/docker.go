@@ -0,0 +1,393 @@
+package connections
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jtopjian/yak/lib/shared"
+	"github.com/jtopjian/yak/lib/utils"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	DockerCommandTimeout    = 60
+	DockerConnectionTimeout = 60
+	DockerDefaultShell      = "/bin/sh"
+)
+
+// Docker represents a connection to a Docker or Podman container.
+type Docker struct {
+	Host    string `mapstructure:"host"`
+	Shell   string `mapstructure:"shell"`
+	Socket  string `mapstructure:"socket"`
+	Timeout int    `mapstructure:"timeout"`
+
+	client *shared.DockerClient
+}
+
+// NewDocker will return a Docker connection.
+func NewDocker(options map[string]interface{}) (*Docker, error) {
+	var dockerConfig Docker
+
+	err := mapstructure.Decode(options, &dockerConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if dockerConfig.Host == "" {
+		return nil, fmt.Errorf("host is required for a docker connection")
+	}
+
+	if dockerConfig.Shell == "" {
+		dockerConfig.Shell = DockerDefaultShell
+	}
+
+	return &dockerConfig, nil
+}
+
+// Connect implements the Connect method of the Connection interface.
+// It will ensure the container is running.
+func (r *Docker) Connect() error {
+	// If a connection has already been made, don't do anything.
+	if r.client != nil {
+		return nil
+	}
+
+	client := shared.NewDockerClient(r.Socket)
+
+	connectTimeout := DockerConnectionTimeout
+	if r.Timeout > 0 {
+		connectTimeout = r.Timeout
+	}
+
+	err := retryFunc(connectTimeout, func() error {
+		running, err := client.ContainerRunning(r.Host)
+		if err != nil {
+			// A missing container won't appear by retrying.
+			if e, ok := err.(shared.DockerError); ok && e.StatusCode == 404 {
+				return stopRetry{err}
+			}
+
+			return err
+		}
+
+		if !running {
+			return fmt.Errorf("container %s is not running", r.Host)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		if err.Error() == "timeout" {
+			return fmt.Errorf("timed out connecting to %s", r.Host)
+		}
+
+		return err
+	}
+
+	r.client = client
+
+	return nil
+}
+
+// RunCommand implements the Run method of the Connection interface.
+func (r *Docker) RunCommand(ro RunOptions) (*RunResult, error) {
+	var rr RunResult
+	var outBuf, errBuf bytes.Buffer
+
+	// validate options
+	command, err := ro.command()
+	if err != nil {
+		return nil, err
+	}
+
+	var env []string
+	for _, k := range sortedKeys(ro.Env) {
+		if !utils.ValidEnvName(k) {
+			return nil, fmt.Errorf("invalid env name: %s", k)
+		}
+
+		env = append(env, fmt.Sprintf("%s=%s", k, ro.Env[k]))
+	}
+
+	timeout := DockerCommandTimeout
+	if ro.Timeout > 0 {
+		timeout = ro.Timeout
+	}
+
+	// Set up the output
+	log := ioutil.Discard
+	if ro.Log != nil {
+		log = *ro.Log
+	}
+
+	outR, outW := io.Pipe()
+	errR, errW := io.Pipe()
+
+	outTee := io.TeeReader(outR, &outBuf)
+	errTee := io.TeeReader(errR, &errBuf)
+	outDoneCh := make(chan struct{})
+	errDoneCh := make(chan struct{})
+	go printOutput(log, outTee, outDoneCh)
+	go printOutput(log, errTee, errDoneCh)
+
+	// The exit code of a command is waited for up to the connection
+	// timeout once its output has closed.
+	connectTimeout := DockerConnectionTimeout
+	if r.Timeout > 0 {
+		connectTimeout = r.Timeout
+	}
+
+	e := shared.DockerExec{
+		Timeout:    time.Duration(connectTimeout) * time.Second,
+		Cmd:        []string{r.Shell, "-c", command},
+		Env:        env,
+		WorkingDir: ro.Dir,
+		Stdin:      ro.Stdin,
+		Stdout:     outW,
+		Stderr:     errW,
+	}
+
+	err = timeoutFunc(timeout, func() error {
+		exitCode, err := r.client.Exec(r.Host, e)
+		if err != nil {
+			return err
+		}
+
+		rr.ExitCode = exitCode
+
+		return nil
+	})
+
+	if err != nil {
+		if err.Error() == "timeout" {
+			rr.Timeout = true
+		}
+	}
+
+	outW.Close()
+	errW.Close()
+	<-outDoneCh
+	<-errDoneCh
+
+	rr.Stdout = strings.TrimSpace(outBuf.String())
+	rr.Stderr = strings.TrimSpace(errBuf.String())
+	rr.Applied = true
+
+	return &rr, err
+}
+
+// FileUpload implements the FileUpload method of the Connection interface.
+// The file is sent as a tar archive which is extracted in the
+// directory of the destination. The owner of the archive is not kept
+// by every server, so an owner other than root is set by a command.
+func (r *Docker) FileUpload(cfo CopyFileOptions) (*FileResult, error) {
+	var fr FileResult
+
+	if cfo.Source == "" {
+		return nil, fmt.Errorf("source is required for file upload")
+	}
+
+	if cfo.Destination == "" {
+		return nil, fmt.Errorf("destination is required for file upload")
+	}
+
+	if cfo.Mode == 0 {
+		cfo.Mode = os.FileMode(0640)
+	}
+
+	timeout := DockerCommandTimeout
+	if cfo.Timeout > 0 {
+		timeout = cfo.Timeout
+	}
+
+	content, err := ioutil.ReadFile(cfo.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+
+	header := &tar.Header{
+		Name:     path.Base(cfo.Destination),
+		Mode:     int64(cfo.Mode.Perm()),
+		Uid:      cfo.UID,
+		Gid:      cfo.GID,
+		Size:     int64(len(content)),
+		ModTime:  time.Now(),
+		Typeflag: tar.TypeReg,
+	}
+
+	if err := tw.WriteHeader(header); err != nil {
+		return nil, err
+	}
+
+	if _, err := tw.Write(content); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	err = timeoutFunc(timeout, func() error {
+		return r.client.PutArchive(r.Host, path.Dir(cfo.Destination), &archive)
+	})
+
+	if err != nil {
+		if err.Error() == "timeout" {
+			fr.Timeout = true
+		}
+
+		fr.Applied = true
+		return &fr, err
+	}
+
+	if cfo.UID == 0 && cfo.GID == 0 {
+		fr.Success = true
+		fr.Applied = true
+		return &fr, nil
+	}
+
+	ro := RunOptions{
+		Args:    []string{"chown", fmt.Sprintf("%d:%d", cfo.UID, cfo.GID), cfo.Destination},
+		Timeout: cfo.Timeout,
+	}
+
+	rr, err := r.RunCommand(ro)
+	fr.Applied = true
+	if err != nil {
+		fr.Timeout = rr != nil && rr.Timeout
+		return &fr, err
+	}
+
+	if rr.ExitCode != 0 {
+		return &fr, fmt.Errorf("unable to set the owner of %s: %s", cfo.Destination, rr.Stderr)
+	}
+
+	fr.Success = true
+
+	return &fr, nil
+}
+
+// FileDownload implements the FileDownload method of the Connection interface.
+// The file is received as a tar archive.
+func (r *Docker) FileDownload(cfo CopyFileOptions) (*FileResult, error) {
+	var fr FileResult
+
+	if cfo.Source == "" {
+		return nil, fmt.Errorf("source is required for file download")
+	}
+
+	if cfo.Destination == "" {
+		return nil, fmt.Errorf("destination is required for file download")
+	}
+
+	if cfo.Mode == 0 {
+		cfo.Mode = os.FileMode(0640)
+	}
+
+	timeout := DockerCommandTimeout
+	if cfo.Timeout > 0 {
+		timeout = cfo.Timeout
+	}
+
+	local, err := os.OpenFile(cfo.Destination, os.O_RDWR|os.O_CREATE|os.O_TRUNC, cfo.Mode)
+	if err != nil {
+		return nil, err
+	}
+	defer local.Close()
+
+	err = timeoutFunc(timeout, func() error {
+		archive, err := r.client.GetArchive(r.Host, cfo.Source)
+		if err != nil {
+			return err
+		}
+		defer archive.Close()
+
+		tr := tar.NewReader(archive)
+		header, err := tr.Next()
+		if err != nil {
+			return err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			return fmt.Errorf("%s is not a file", cfo.Source)
+		}
+
+		_, err = io.Copy(local, tr)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		if err.Error() == "timeout" {
+			fr.Timeout = true
+		}
+	}
+
+	if err == nil {
+		fr.Success = true
+	}
+
+	fr.Applied = true
+
+	return &fr, err
+}
+
+// FileInfo implements the FileInfo method of the Connection interface.
+func (r *Docker) FileInfo(fo FileOptions) (*FileResult, error) {
+	return statFile(r, fo)
+}
+
+// FileDelete implements the FileDelete method of the Connection interface.
+// The Engine API can't delete files, so the file is removed by a
+// command.
+func (r *Docker) FileDelete(fo FileOptions) (*FileResult, error) {
+	var fr FileResult
+
+	// validate options
+	if fo.Path == "" {
+		return nil, fmt.Errorf("path is required for file delete")
+	}
+
+	ro := RunOptions{
+		Args:    []string{"rm", "-f", fo.Path},
+		Timeout: fo.Timeout,
+	}
+
+	rr, err := r.RunCommand(ro)
+	if err != nil {
+		fr.Timeout = rr != nil && rr.Timeout
+		return &fr, err
+	}
+
+	if rr.ExitCode != 0 {
+		return &fr, fmt.Errorf("unable to delete %s: %s", fo.Path, rr.Stderr)
+	}
+
+	fr.Success = true
+	fr.Applied = true
+
+	return &fr, nil
+}
+
+// Close implements the Close method of the Connection interface.
+// Communication with the Engine API is not persistent, so the
+// client is only forgotten.
+func (r *Docker) Close() {
+	r.client = nil
+}
@@ -56,12 +56,13 @@ func (r Local) RunCommand(ro RunOptions) (*RunResult, error) {
 	var outBuf, errBuf bytes.Buffer
 
 	// Validate options
-	if ro.Command == "" {
-		return nil, fmt.Errorf("a command is required")
+	command, err := ro.command()
+	if err != nil {
+		return nil, err
 	}
 
 	// Build the command
-	cmdArgs := []string{r.Shell, "-c", ro.Command}
+	cmdArgs := []string{r.Shell, "-c", command}
 	r.c = exec.Command(cmdArgs[0], cmdArgs[1:]...)
 
 	// Set up the output
@@ -80,6 +81,15 @@ func (r Local) RunCommand(ro RunOptions) (*RunResult, error) {
 		return nil, err
 	}
 
+	r.c.Dir = ro.Dir
+	r.c.Stdin = ro.Stdin
+
+	if len(ro.Env) > 0 {
+		r.c.Env = os.Environ()
+		for _, k := range sortedKeys(ro.Env) {
+			r.c.Env = append(r.c.Env, fmt.Sprintf("%s=%s", k, ro.Env[k]))
+		}
+	}
 	r.c.Stdout = outW
 	r.c.Stderr = errW
 
@@ -274,7 +284,7 @@ func (r Local) copyFile(fo CopyFileOptions) (*FileResult, error) {
 		timeout = fo.Timeout
 	}
 
-	destination, err := os.OpenFile(fo.Destination, os.O_RDWR|os.O_CREATE, fo.Mode)
+	destination, err := os.OpenFile(fo.Destination, os.O_RDWR|os.O_CREATE|os.O_TRUNC, fo.Mode)
 	if err != nil {
 		return nil, err
 	}
@@ -306,6 +316,11 @@ func (r Local) copyFile(fo CopyFileOptions) (*FileResult, error) {
 	destination.Close()
 	source.Close()
 
+	// The mode is also set on an existing file.
+	if err := os.Chmod(fo.Destination, fo.Mode); err != nil {
+		return nil, err
+	}
+
 	if err := os.Chown(fo.Destination, fo.UID, fo.GID); err != nil {
 		return nil, err
 	}
@@ -6,7 +6,6 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"strconv"
 	"strings"
 
 	"github.com/jtopjian/yak/lib/config"
@@ -109,8 +108,9 @@ func (r LXD) RunCommand(ro RunOptions) (*RunResult, error) {
 	var outBuf, errBuf bytes.Buffer
 
 	// validate options
-	if ro.Command == "" {
-		return nil, fmt.Errorf("a command is required")
+	command, err := ro.command()
+	if err != nil {
+		return nil, err
 	}
 
 	timeout := LXDCommandTimeout
@@ -127,8 +127,13 @@ func (r LXD) RunCommand(ro RunOptions) (*RunResult, error) {
 	outR, outW := io.Pipe()
 	errR, errW := io.Pipe()
 
+	stdin := ro.Stdin
+	if stdin == nil {
+		stdin = bytes.NewReader(nil)
+	}
+
 	args := lxd.ContainerExecArgs{
-		Stdin:    ioutil.NopCloser(bytes.NewReader(nil)),
+		Stdin:    ioutil.NopCloser(stdin),
 		Stderr:   errW,
 		Stdout:   outW,
 		DataDone: make(chan bool),
@@ -142,14 +147,15 @@ func (r LXD) RunCommand(ro RunOptions) (*RunResult, error) {
 	go printOutput(log, outTee, outDoneCh)
 	go printOutput(log, errTee, errDoneCh)
 
-	//cmd := strings.Replace(ro.Command, `"`, `\"`, -1)
 	req := lxd_api.ContainerExecPost{
-		Command:     []string{r.Shell, "-c", ro.Command},
+		Command:     []string{r.Shell, "-c", command},
 		WaitForWS:   true,
 		Interactive: false,
+		Environment: ro.Env,
+		Cwd:         ro.Dir,
 	}
 
-	err := timeoutFunc(timeout, func() error {
+	err = timeoutFunc(timeout, func() error {
 		op, err := r.client.ExecContainer(r.Host, req, &args)
 		if err != nil {
 			return err
@@ -302,78 +308,7 @@ func (r LXD) FileDownload(cfo CopyFileOptions) (*FileResult, error) {
 
 // FileInfo implements the FileInfo method of the Connection interface.
 func (r LXD) FileInfo(fo FileOptions) (*FileResult, error) {
-	var fr FileResult
-
-	// validate options
-	if fo.Path == "" {
-		return nil, fmt.Errorf("path is required for file exists")
-	}
-
-	ro := RunOptions{
-		Command: fmt.Sprintf(`stat -c"%u:%g:%n:%s:%a:%F" "%s"`, fo.Path),
-		Timeout: fo.Timeout,
-	}
-
-	rr, err := r.RunCommand(ro)
-	if err != nil {
-		return &fr, err
-	}
-
-	if rr.ExitCode != 0 {
-		fr.Exists = false
-		fr.Success = true
-		return &fr, nil
-	}
-
-	parts := strings.Split(rr.Stdout, ":")
-	if len(parts) != 6 {
-		return nil, fmt.Errorf("unable to get file information for %s", fo.Path)
-	}
-
-	uid, err := strconv.Atoi(parts[0])
-	if err != nil {
-		return nil, fmt.Errorf("unable to get file information for %s", fo.Path)
-	}
-
-	gid, err := strconv.Atoi(parts[1])
-	if err != nil {
-		return nil, fmt.Errorf("unable to get file information for %s", fo.Path)
-	}
-
-	size, err := strconv.ParseInt(parts[3], 10, 64)
-	if err != nil {
-		return nil, fmt.Errorf("unable to get file information for %s", fo.Path)
-	}
-
-	mode, err := strconv.Atoi(parts[4])
-	if err != nil {
-		return nil, fmt.Errorf("unable to get file information for %s", fo.Path)
-	}
-
-	fi := FileInfo{
-		UID:  uid,
-		GID:  gid,
-		Name: parts[2],
-		Size: size,
-		Mode: mode,
-	}
-
-	switch parts[5] {
-	case "regular file":
-		fi.Type = "file"
-	case "directory":
-		fi.Type = "directory"
-	case "symbolic link":
-		fi.Type = "symlink"
-	case "socket":
-		fi.Type = "socket"
-	}
-
-	fr.Exists = true
-	fr.Success = true
-	fr.Applied = true
-
-	return &fr, nil
+	return statFile(&r, fo)
 }
 
 // FileDelete implements the FileDelete method of the Connection interface.
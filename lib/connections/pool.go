@@ -0,0 +1,100 @@
+package connections
+
+import (
+	"sync"
+)
+
+// Pool holds the open connections of a run so each host is only
+// connected to once. Connections are identified by a key, such as
+// the name of the connection and the host.
+type Pool struct {
+	entries map[string]*poolEntry
+	mux     sync.Mutex
+}
+
+// poolEntry is a connection of a pool. Each entry has its own lock
+// so hosts can be connected to in parallel.
+type poolEntry struct {
+	conn      Connection
+	connected bool
+	mux       sync.Mutex
+}
+
+// Checker is implemented by connections which can determine if an
+// open connection is still usable.
+type Checker interface {
+	Alive() bool
+}
+
+// NewPool will return an empty Pool.
+func NewPool() *Pool {
+	return &Pool{
+		entries: make(map[string]*poolEntry),
+	}
+}
+
+// Get returns the open connection of a key. If the key is not in
+// the pool yet, conn is connected and added to the pool. If the
+// connection of the key was lost, it is reconnected.
+func (r *Pool) Get(key string, conn Connection) (Connection, error) {
+	r.mux.Lock()
+	entry, ok := r.entries[key]
+	if !ok {
+		entry = &poolEntry{conn: conn}
+		r.entries[key] = entry
+	}
+	r.mux.Unlock()
+
+	entry.mux.Lock()
+	defer entry.mux.Unlock()
+
+	if entry.connected {
+		checker, ok := entry.conn.(Checker)
+		if !ok || checker.Alive() {
+			return entry.conn, nil
+		}
+
+		entry.conn.Close()
+		entry.connected = false
+	}
+
+	if err := entry.conn.Connect(); err != nil {
+		return nil, err
+	}
+	entry.connected = true
+
+	return entry.conn, nil
+}
+
+// Len returns the amount of open connections in the pool.
+func (r *Pool) Len() int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	var n int
+	for _, entry := range r.entries {
+		entry.mux.Lock()
+		if entry.connected {
+			n++
+		}
+		entry.mux.Unlock()
+	}
+
+	return n
+}
+
+// CloseAll will close all connections of the pool.
+func (r *Pool) CloseAll() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	for key, entry := range r.entries {
+		entry.mux.Lock()
+		if entry.connected {
+			entry.conn.Close()
+		}
+		entry.mux.Unlock()
+
+		delete(r.entries, key)
+	}
+}
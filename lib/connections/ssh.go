@@ -10,10 +10,11 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-	"syscall"
+	"sync"
 
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/jtopjian/yak/lib/utils"
 
 	"github.com/mitchellh/go-homedir"
 	"github.com/mitchellh/mapstructure"
@@ -30,29 +31,57 @@ const (
 	SSHConnectionTimeout = 300
 
 	SCPMaxPacketSize = 32768
-	SCPMaxBytes      = 4096
 )
 
 // SSH represents an SSH connection.
 type SSH struct {
-	Agent      bool   `mapstructure:"agent"`
-	Host       string `mapstructure:"host"`
-	PrivateKey string `mapstructure:"private_key"`
-	Port       int    `mapstructure:"port"`
-	Shell      string `mapstructure:"shell"`
-	Timeout    int    `mapstructure:"timeout"`
-	User       string `mapstructure:"user"`
+	Agent       bool   `mapstructure:"agent"`
+	AuthEntry   string `mapstructure:"auth"`
+	Certificate string `mapstructure:"certificate"`
+	Host        string `mapstructure:"host"`
+	PrivateKey  string `mapstructure:"private_key"`
+	Port        int    `mapstructure:"port"`
+	Shell       string `mapstructure:"shell"`
+	Timeout     int    `mapstructure:"timeout"`
+	User        string `mapstructure:"user"`
 
 	BastionUser       string `mapstructure:"bastion_user"`
 	BastionPrivateKey string `mapstructure:"bastion_private_key"`
 	BastionHost       string `mapstructure:"bastion_host"`
 	BastionPort       int    `mapstructure:"bastion_port"`
 
-	bastionConfig *ssh.ClientConfig
-	bastionConn   *net.Conn
-	client        *ssh.Client
-	config        *ssh.ClientConfig
-	sftp          *sftp.Client
+	JumpHosts    []SSHJumpHost `mapstructure:"jump_hosts"`
+	ProxyCommand string        `mapstructure:"proxy_command"`
+
+	HostKeyChecking string `mapstructure:"host_key_checking"`
+	KnownHostsFile  string `mapstructure:"known_hosts_file"`
+
+	UseSSHConfig  bool   `mapstructure:"use_ssh_config"`
+	SSHConfigFile string `mapstructure:"ssh_config_file"`
+
+	auth        SSHAuth
+	client      *ssh.Client
+	config      *ssh.ClientConfig
+	hostKeys    *hostKeyChecker
+	jumpClients []*ssh.Client
+	sftp        *sftp.Client
+	sftpMux     sync.Mutex
+}
+
+// SSHJumpHost represents a host which is jumped through to reach
+// the host. Jump hosts are connected to in order, each through the
+// one before it.
+type SSHJumpHost struct {
+	Agent       *bool  `mapstructure:"agent"`
+	AuthEntry   string `mapstructure:"auth"`
+	Certificate string `mapstructure:"certificate"`
+	Host        string `mapstructure:"host"`
+	Port        int    `mapstructure:"port"`
+	PrivateKey  string `mapstructure:"private_key"`
+	User        string `mapstructure:"user"`
+
+	auth   SSHAuth
+	config *ssh.ClientConfig
 }
 
 // NewSSH will return an SSH client.
@@ -68,7 +97,14 @@ func NewSSH(options map[string]interface{}) (*SSH, error) {
 		return nil, fmt.Errorf("a host is requried for ssh")
 	}
 
-	var signer ssh.Signer
+	// Options from an OpenSSH client config file are applied
+	// before any defaults.
+	if sshConfig.UseSSHConfig || sshConfig.SSHConfigFile != "" {
+		if err := sshConfig.applySSHConfig(); err != nil {
+			return nil, err
+		}
+	}
+
 	if sshConfig.PrivateKey == "" {
 		// If no private_key was specified, try using $user/.ssh/id_rsa.
 		if homeDir, err := homedir.Dir(); err == nil {
@@ -79,89 +115,99 @@ func NewSSH(options map[string]interface{}) (*SSH, error) {
 		}
 	}
 
-	if sshConfig.PrivateKey != "" {
-		privateKey, err := homedir.Expand(sshConfig.PrivateKey)
-		if err != nil {
+	// Passwords and passphrases are read from an auth entry.
+	if sshConfig.AuthEntry != "" {
+		if err := sshConfig.readAuthEntry(); err != nil {
 			return nil, err
 		}
+	}
 
-		if _, err := os.Stat(privateKey); os.IsNotExist(err) {
-			return nil, fmt.Errorf("private_key %s does not exist", privateKey)
-		}
-
-		key, err := ioutil.ReadFile(privateKey)
-		if err != nil {
-			return nil, err
-		}
-
-		signer, err = ssh.ParsePrivateKey(key)
+	var signer ssh.Signer
+	if sshConfig.PrivateKey != "" {
+		signer, err = readSSHSigner(sshConfig.PrivateKey, sshConfig.Certificate, sshConfig.auth.Passphrase)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	var bastionSigner ssh.Signer
-	if sshConfig.BastionPrivateKey != "" {
-		key, err := ioutil.ReadFile(sshConfig.BastionPrivateKey)
-		if err != nil {
-			return nil, err
+	// A bastion host is the first jump host.
+	if sshConfig.BastionHost != "" {
+		bastion := SSHJumpHost{
+			Host:       sshConfig.BastionHost,
+			Port:       sshConfig.BastionPort,
+			PrivateKey: sshConfig.BastionPrivateKey,
+			User:       sshConfig.BastionUser,
 		}
 
-		bastionSigner, err = ssh.ParsePrivateKey(key)
-		if err != nil {
-			return nil, err
-		}
+		sshConfig.JumpHosts = append([]SSHJumpHost{bastion}, sshConfig.JumpHosts...)
 	}
 
 	if sshConfig.Port == 0 {
 		sshConfig.Port = SSHDefaultPort
 	}
 
-	if sshConfig.BastionPort == 0 {
-		sshConfig.BastionPort = SSHDefaultPort
-	}
-
 	if sshConfig.User == "" {
 		sshConfig.User = SSHDefaultUser
 	}
 
-	if sshConfig.BastionUser == "" {
-		sshConfig.BastionUser = SSHDefaultUser
-	}
-
 	if sshConfig.Shell == "" {
 		sshConfig.Shell = SSHDefaultShell
 	}
 
-	authMethod := ssh.PublicKeys(signer)
-	if sshConfig.Agent {
-		if sshAgent, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK")); err == nil {
-			authMethod = ssh.PublicKeysCallback(agent.NewClient(sshAgent).Signers)
-		}
+	sshConfig.hostKeys, err = newHostKeyChecker(sshConfig.HostKeyChecking, sshConfig.KnownHostsFile)
+	if err != nil {
+		return nil, err
 	}
 
 	sshConfig.config = &ssh.ClientConfig{
-		User: sshConfig.User,
-		Auth: []ssh.AuthMethod{
-			authMethod,
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		User:            sshConfig.User,
+		Auth:            sshAuthMethods(sshConfig.Agent, signer, sshConfig.auth.Password),
+		HostKeyCallback: sshConfig.hostKeys.callback(),
 	}
 
-	if sshConfig.BastionHost != "" {
-		bastionAuthMethod := ssh.PublicKeys(bastionSigner)
-		if sshConfig.Agent {
-			if bastionAgent, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK")); err == nil {
-				bastionAuthMethod = ssh.PublicKeysCallback(agent.NewClient(bastionAgent).Signers)
+	// Each jump host has its own auth. If no private_key was
+	// specified for a jump host, the private key of the host is used,
+	// and if agent was not specified, the agent option of the host is
+	// used. The password of the host is never sent to a jump host.
+	for i := range sshConfig.JumpHosts {
+		jump := &sshConfig.JumpHosts[i]
+
+		if jump.Host == "" {
+			return nil, fmt.Errorf("a host is required for jump host %d", i+1)
+		}
+
+		if jump.Port == 0 {
+			jump.Port = SSHDefaultPort
+		}
+
+		if jump.User == "" {
+			jump.User = SSHDefaultUser
+		}
+
+		if jump.AuthEntry != "" {
+			jump.auth, err = readSSHAuthEntry(jump.AuthEntry)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read auth of jump host %s: %s", jump.Host, err)
 			}
 		}
 
-		sshConfig.bastionConfig = &ssh.ClientConfig{
-			User: sshConfig.BastionUser,
-			Auth: []ssh.AuthMethod{
-				bastionAuthMethod,
-			},
-			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		jumpSigner := signer
+		if jump.PrivateKey != "" {
+			jumpSigner, err = readSSHSigner(jump.PrivateKey, jump.Certificate, jump.auth.Passphrase)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		useAgent := sshConfig.Agent
+		if jump.Agent != nil {
+			useAgent = *jump.Agent
+		}
+
+		jump.config = &ssh.ClientConfig{
+			User:            jump.User,
+			Auth:            sshAuthMethods(useAgent, jumpSigner, jump.auth.Password),
+			HostKeyCallback: sshConfig.hostKeys.callback(),
 		}
 	}
 
@@ -184,49 +230,108 @@ func (r *SSH) Connect() error {
 	}
 
 	host := fmt.Sprintf("%s:%d", r.Host, r.Port)
-	bastionHost := fmt.Sprintf("%s:%d", r.BastionHost, r.BastionPort)
 
-	err = retryFunc(connectTimeout, func() error {
-		if r.BastionHost != "" {
-			r.client, err = ssh.Dial("tcp", bastionHost, r.bastionConfig)
-			if err != nil {
-				return err
-			}
+	err = retryFunc(connectTimeout, r.dial)
 
-			conn, err := r.client.Dial("tcp", host)
-			if err != nil {
-				return err
-			}
-			r.bastionConn = &conn
+	if err != nil {
+		if err.Error() == "timeout" {
+			return fmt.Errorf("timed out connecting to %s", host)
+		}
+
+		return fmt.Errorf("unable to connect to %s: %s", host, err)
+	}
 
-			return nil
+	return nil
+}
+
+// sshHop is a single SSH connection of the path to a host.
+type sshHop struct {
+	host   string
+	port   int
+	config *ssh.ClientConfig
+}
+
+// dial will connect to the host through its jump hosts, if any.
+// The SSH connection to each host is made over a connection which
+// is forwarded by the host before it. The first connection is made
+// through the proxy_command, if one was specified.
+func (r *SSH) dial() error {
+	var hops []sshHop
+	for _, jump := range r.JumpHosts {
+		hops = append(hops, sshHop{jump.Host, jump.Port, jump.config})
+	}
+	hops = append(hops, sshHop{r.Host, r.Port, r.config})
+
+	var clients []*ssh.Client
+	closeClients := func() {
+		for i := len(clients) - 1; i >= 0; i-- {
+			clients[i].Close()
+		}
+	}
+
+	for i, hop := range hops {
+		// Targets bracket IPv6 addresses so a port can be appended.
+		hop.host = strings.Trim(hop.host, "[]")
+		addr := net.JoinHostPort(hop.host, strconv.Itoa(hop.port))
+
+		var conn net.Conn
+		var err error
+		switch {
+		case i > 0:
+			conn, err = clients[i-1].Dial("tcp", addr)
+		case r.ProxyCommand != "":
+			conn, err = newProxyCommandConn(r.ProxyCommand, hop.host, hop.port, hop.config.User)
+		default:
+			conn, err = net.Dial("tcp", addr)
 		}
 
-		r.client, err = ssh.Dial("tcp", host, r.config)
 		if err != nil {
+			closeClients()
 			return err
 		}
 
-		return nil
-	})
-
-	if err != nil {
-		if err.Error() == "timeout" {
-			return fmt.Errorf("timed out connecting to %s", host)
+		c, chans, reqs, err := ssh.NewClientConn(conn, addr, hop.config)
+		if err != nil {
+			conn.Close()
+			closeClients()
+			return r.dialError(err)
 		}
+
+		clients = append(clients, ssh.NewClient(c, chans, reqs))
 	}
 
+	r.jumpClients = clients[:len(clients)-1]
+	r.client = clients[len(clients)-1]
+
 	return nil
 }
 
+// dialError determines if an error from dialing a host should be
+// retried. Host key errors are never retried.
+func (r *SSH) dialError(err error) error {
+	if r.hostKeys.err != nil {
+		return stopRetry{r.hostKeys.err}
+	}
+
+	return err
+}
+
 // RunCommand implements the Run method of the Connection interface.
-func (r SSH) RunCommand(ro RunOptions) (*RunResult, error) {
+func (r *SSH) RunCommand(ro RunOptions) (*RunResult, error) {
 	var rr RunResult
 	var outBuf, errBuf bytes.Buffer
 
 	// validate options
-	if ro.Command == "" {
-		return nil, fmt.Errorf("a command is required")
+	command, err := ro.command()
+	if err != nil {
+		return nil, err
+	}
+
+	// Environment variables are exported by the command itself
+	// since most SSH servers only accept a few variables.
+	command, err = utils.ShellScript(command, ro.Dir, ro.Env)
+	if err != nil {
+		return nil, err
 	}
 
 	timeout := SSHCommandTimeout
@@ -250,6 +355,7 @@ func (r SSH) RunCommand(ro RunOptions) (*RunResult, error) {
 	outR, outW := io.Pipe()
 	errR, errW := io.Pipe()
 
+	session.Stdin = ro.Stdin
 	session.Stdout = outW
 	session.Stderr = errW
 
@@ -260,8 +366,9 @@ func (r SSH) RunCommand(ro RunOptions) (*RunResult, error) {
 	go printOutput(log, outTee, outDoneCh)
 	go printOutput(log, errTee, errDoneCh)
 
-	//cmd := strings.Replace(ro.Command, `"`, `\"`, -1)
-	cmd := fmt.Sprintf(`%s -c "%s"`, r.Shell, ro.Command)
+	// The SSH server runs the command with the login shell of the
+	// user, so the script is quoted to reach r.Shell unchanged.
+	cmd := fmt.Sprintf("%s -c %s", r.Shell, utils.ShellQuote(command))
 
 	err = timeoutFunc(timeout, func() error {
 		if err := session.Start(cmd); err != nil {
@@ -299,17 +406,17 @@ func (r SSH) RunCommand(ro RunOptions) (*RunResult, error) {
 }
 
 // FileUpload implements the FileUpload method of the Connection interface.
-func (r SSH) FileUpload(cfo CopyFileOptions) (*FileResult, error) {
+func (r *SSH) FileUpload(cfo CopyFileOptions) (*FileResult, error) {
 	return r.copyFile(cfo, "upload")
 }
 
 // FileDownload implements the FileUpload method of the Connection interface.
-func (r SSH) FileDownload(cfo CopyFileOptions) (*FileResult, error) {
+func (r *SSH) FileDownload(cfo CopyFileOptions) (*FileResult, error) {
 	return r.copyFile(cfo, "download")
 }
 
 // FileInfo implements the FileInfo method of the Connection interface.
-func (r SSH) FileInfo(fo FileOptions) (*FileResult, error) {
+func (r *SSH) FileInfo(fo FileOptions) (*FileResult, error) {
 	var fr FileResult
 	var fi FileInfo
 	var err error
@@ -323,19 +430,23 @@ func (r SSH) FileInfo(fo FileOptions) (*FileResult, error) {
 		timeout = fo.Timeout
 	}
 
-	client, err := sftp.NewClient(r.client, sftp.MaxPacket(SCPMaxPacketSize))
+	client, err := r.sftpClient()
 	if err != nil {
 		return nil, err
 	}
-	defer client.Close()
 
 	err = timeoutFunc(timeout, func() error {
 		stat, err := client.Stat(fo.Path)
 		if err == nil {
 			fi.Name = stat.Name()
 			fi.Size = stat.Size()
-			fi.UID = int(stat.Sys().(*syscall.Stat_t).Uid)
-			fi.GID = int(stat.Sys().(*syscall.Stat_t).Gid)
+
+			// The owner is sent by the SFTP server, not read
+			// from the local file system.
+			if fs, ok := stat.Sys().(*sftp.FileStat); ok {
+				fi.UID = int(fs.UID)
+				fi.GID = int(fs.GID)
+			}
 
 			mode := fmt.Sprintf("%o", int(stat.Mode().Perm()))
 			fi.Mode, _ = strconv.Atoi(mode)
@@ -381,7 +492,7 @@ func (r SSH) FileInfo(fo FileOptions) (*FileResult, error) {
 }
 
 // FileDelete implements the FileDelete method of the Connection interface.
-func (r SSH) FileDelete(fo FileOptions) (*FileResult, error) {
+func (r *SSH) FileDelete(fo FileOptions) (*FileResult, error) {
 	var fr FileResult
 
 	// validate options
@@ -394,11 +505,10 @@ func (r SSH) FileDelete(fo FileOptions) (*FileResult, error) {
 		timeout = fo.Timeout
 	}
 
-	client, err := sftp.NewClient(r.client, sftp.MaxPacket(SCPMaxPacketSize))
+	client, err := r.sftpClient()
 	if err != nil {
 		return nil, err
 	}
-	defer client.Close()
 
 	err = timeoutFunc(timeout, func() error {
 		if err := client.Remove(fo.Path); err != nil {
@@ -424,22 +534,63 @@ func (r SSH) FileDelete(fo FileOptions) (*FileResult, error) {
 }
 
 // Close implements the Close method of the Connection interface.
-// It will close an SSH and bastion connection if they are opened.
-func (r SSH) Close() {
-	if r.bastionConn != nil {
-		(*r.bastionConn).Close()
-		r.bastionConn = nil
+// It will close an SSH connection and the connections to its jump
+// hosts if they are opened.
+func (r *SSH) Close() {
+	r.sftpMux.Lock()
+	if r.sftp != nil {
+		r.sftp.Close()
+		r.sftp = nil
 	}
+	r.sftpMux.Unlock()
 
 	if r.client != nil {
 		r.client.Close()
 		r.client = nil
 	}
 
+	for i := len(r.jumpClients) - 1; i >= 0; i-- {
+		r.jumpClients[i].Close()
+	}
+	r.jumpClients = nil
+}
+
+// Alive implements the Checker interface. It determines if the
+// connection to the host is still open by sending a keepalive.
+func (r *SSH) Alive() bool {
+	if r.client == nil {
+		return false
+	}
+
+	_, _, err := r.client.SendRequest("keepalive@openssh.com", true, nil)
+	return err == nil
+}
+
+// sftpClient returns the SFTP client of the connection. The client
+// is created once and reused by all file operations.
+func (r *SSH) sftpClient() (*sftp.Client, error) {
+	r.sftpMux.Lock()
+	defer r.sftpMux.Unlock()
+
+	if r.sftp != nil {
+		return r.sftp, nil
+	}
+
+	if r.client == nil {
+		return nil, fmt.Errorf("not connected to %s", r.Host)
+	}
+
+	client, err := sftp.NewClient(r.client, sftp.MaxPacket(SCPMaxPacketSize))
+	if err != nil {
+		return nil, err
+	}
+	r.sftp = client
+
+	return client, nil
 }
 
 // copyFile is an internal function to manage both Upload and Download.
-func (r SSH) copyFile(cfo CopyFileOptions, action string) (*FileResult, error) {
+func (r *SSH) copyFile(cfo CopyFileOptions, action string) (*FileResult, error) {
 	var fr FileResult
 
 	// validate options
@@ -460,17 +611,16 @@ func (r SSH) copyFile(cfo CopyFileOptions, action string) (*FileResult, error) {
 		timeout = cfo.Timeout
 	}
 
-	client, err := sftp.NewClient(r.client, sftp.MaxPacket(SCPMaxPacketSize))
+	client, err := r.sftpClient()
 	if err != nil {
 		return nil, err
 	}
-	defer client.Close()
 
 	var remote *sftp.File
 	var local *os.File
 	switch action {
 	case "upload":
-		remote, err = client.OpenFile(cfo.Destination, os.O_RDWR|os.O_CREATE)
+		remote, err = client.OpenFile(cfo.Destination, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
 		if err != nil {
 			return nil, err
 		}
@@ -482,7 +632,7 @@ func (r SSH) copyFile(cfo CopyFileOptions, action string) (*FileResult, error) {
 		}
 		defer local.Close()
 	case "download":
-		local, err = os.OpenFile(cfo.Destination, os.O_RDWR|os.O_CREATE, cfo.Mode)
+		local, err = os.OpenFile(cfo.Destination, os.O_RDWR|os.O_CREATE|os.O_TRUNC, cfo.Mode)
 		if err != nil {
 			return nil, err
 		}
@@ -498,11 +648,24 @@ func (r SSH) copyFile(cfo CopyFileOptions, action string) (*FileResult, error) {
 	err = timeoutFunc(timeout, func() error {
 		switch action {
 		case "upload":
-			if _, err := io.Copy(remote, io.LimitReader(local, SCPMaxBytes)); err != nil {
+			if _, err := io.Copy(remote, local); err != nil {
 				return err
 			}
+
+			// The mode is also set on an existing file. Only root
+			// can give a file to another user, so the owner is only
+			// set if one was given.
+			if err := remote.Chmod(cfo.Mode); err != nil {
+				return err
+			}
+
+			if cfo.UID != 0 || cfo.GID != 0 {
+				if err := remote.Chown(cfo.UID, cfo.GID); err != nil {
+					return err
+				}
+			}
 		case "download":
-			if _, err := io.Copy(local, io.LimitReader(remote, SCPMaxBytes)); err != nil {
+			if _, err := io.Copy(local, remote); err != nil {
 				return err
 			}
 		}
@@ -0,0 +1,220 @@
+package connections
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/jtopjian/yak/lib/config"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/mitchellh/mapstructure"
+)
+
+// SSHAuth represents the options of an auth entry for an SSH
+// connection. Secrets are kept in the yak config file rather than
+// in the yakfile.
+type SSHAuth struct {
+	Password   string `mapstructure:"password"`
+	Passphrase string `mapstructure:"passphrase"`
+}
+
+// sshPassphrases caches the passphrases which were prompted for,
+// so a passphrase is only prompted for once per private key.
+var (
+	sshPassphrases    = map[string][]byte{}
+	sshPassphrasesMux sync.Mutex
+)
+
+// readAuthEntry will read the auth entry of the connection from the
+// yak config file.
+func (r *SSH) readAuthEntry() error {
+	auth, err := readSSHAuthEntry(r.AuthEntry)
+	if err != nil {
+		return err
+	}
+
+	r.auth = auth
+
+	return nil
+}
+
+// readSSHAuthEntry will read an auth entry of an SSH connection or
+// a jump host from the yak config file.
+func readSSHAuthEntry(name string) (SSHAuth, error) {
+	var auth SSHAuth
+
+	yakConf, err := config.FindAndLoad()
+	if err != nil {
+		return auth, err
+	}
+
+	entry, err := yakConf.GetAuthEntry(name)
+	if err != nil {
+		return auth, err
+	}
+
+	err = mapstructure.Decode(entry.Options, &auth)
+
+	return auth, err
+}
+
+// sshAuthMethods returns the auth methods for a signer. If useAgent
+// is true and an agent is running, the agent is used instead of the
+// signer. If a password was given, password and keyboard-interactive
+// auth are also used.
+func sshAuthMethods(useAgent bool, signer ssh.Signer, password string) []ssh.AuthMethod {
+	var methods []ssh.AuthMethod
+
+	if useAgent {
+		if sshAgent, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK")); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(sshAgent).Signers))
+		}
+	}
+
+	if len(methods) == 0 && signer != nil {
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+
+	if password != "" {
+		methods = append(methods,
+			ssh.Password(password),
+			ssh.KeyboardInteractive(keyboardInteractive(password)),
+		)
+	}
+
+	return methods
+}
+
+// keyboardInteractive returns a function which answers the questions
+// of keyboard-interactive auth. Questions which are not echoed are
+// asked for a password.
+func keyboardInteractive(password string) ssh.KeyboardInteractiveChallenge {
+	return func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i := range questions {
+			if !echos[i] {
+				answers[i] = password
+			}
+		}
+
+		return answers, nil
+	}
+}
+
+// readSSHSigner will read and parse a private key. An encrypted key
+// is decrypted with the passphrase of an auth entry, or a passphrase
+// is prompted for if yak is running in a terminal. If a certificate
+// was specified, or one exists next to the key as <key>-cert.pub,
+// the certificate is used with the key.
+func readSSHSigner(privateKey, certificate, passphrase string) (ssh.Signer, error) {
+	privateKey, err := homedir.Expand(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(privateKey); os.IsNotExist(err) {
+		return nil, fmt.Errorf("private_key %s does not exist", privateKey)
+	}
+
+	key, err := ioutil.ReadFile(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+	if _, ok := err.(*ssh.PassphraseMissingError); ok {
+		signer, err = decryptPrivateKey(privateKey, key, passphrase)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read private_key %s: %s", privateKey, err)
+	}
+
+	if certificate == "" {
+		certificate = privateKey + "-cert.pub"
+		if _, err := os.Stat(certificate); err != nil {
+			return signer, nil
+		}
+	}
+
+	return certSigner(signer, certificate)
+}
+
+// decryptPrivateKey will decrypt an encrypted private key.
+func decryptPrivateKey(privateKey string, key []byte, passphrase string) (ssh.Signer, error) {
+	if passphrase != "" {
+		return ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+	}
+
+	prompted, err := promptPassphrase(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.ParsePrivateKeyWithPassphrase(key, prompted)
+}
+
+// promptPassphrase will prompt for the passphrase of a private key.
+// The passphrase is cached so that many hosts using the same key
+// only prompt once.
+func promptPassphrase(privateKey string) ([]byte, error) {
+	sshPassphrasesMux.Lock()
+	defer sshPassphrasesMux.Unlock()
+
+	if v, ok := sshPassphrases[privateKey]; ok {
+		return v, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return nil, fmt.Errorf("the key is encrypted and no passphrase was given")
+	}
+
+	fmt.Fprintf(os.Stderr, "Enter passphrase for %s: ", privateKey)
+	passphrase, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	sshPassphrases[privateKey] = passphrase
+
+	return passphrase, nil
+}
+
+// certSigner returns a signer which uses the certificate of a key.
+func certSigner(signer ssh.Signer, certificate string) (ssh.Signer, error) {
+	certificate, err := homedir.Expand(certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadFile(certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read certificate %s: %s", certificate, err)
+	}
+
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a certificate", certificate)
+	}
+
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("unable to use certificate %s: %s", certificate, err)
+	}
+
+	return certSigner, nil
+}
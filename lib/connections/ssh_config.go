@@ -0,0 +1,522 @@
+package connections
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// SSHDefaultConfigFile is the OpenSSH client config file which is
+// read when one was not specified.
+const SSHDefaultConfigFile = "~/.ssh/config"
+
+// sshClientConfig represents an OpenSSH client config file.
+// Only the Host and Include keywords are supported to select
+// options. Match blocks are ignored.
+type sshClientConfig struct {
+	blocks []sshConfigBlock
+}
+
+// sshConfigBlock represents a Host block of an OpenSSH client
+// config file. Options which appear before the first Host block
+// apply to all hosts.
+type sshConfigBlock struct {
+	patterns []string
+	match    bool
+	options  []sshConfigOption
+}
+
+// sshConfigOption represents a single option of a Host block.
+// The keyword is lower case.
+type sshConfigOption struct {
+	keyword string
+	value   string
+}
+
+// readSSHClientConfig will read and parse an OpenSSH client config
+// file.
+func readSSHClientConfig(file string) (*sshClientConfig, error) {
+	config := &sshClientConfig{
+		blocks: []sshConfigBlock{
+			{patterns: []string{"*"}},
+		},
+	}
+
+	if err := config.parseFile(file, 0); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// parseFile will parse a config file and add its blocks to the config.
+func (r *sshClientConfig) parseFile(file string, depth int) error {
+	if depth > 16 {
+		return fmt.Errorf("too many nested includes in %s", file)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("unable to read ssh config %s: %s", file, err)
+	}
+	defer f.Close()
+
+	return r.parse(f, file, depth)
+}
+
+// parse will parse the contents of a config file.
+func (r *sshClientConfig) parse(reader io.Reader, file string, depth int) error {
+	scanner := bufio.NewScanner(reader)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		keyword, args := splitSSHConfigLine(scanner.Text())
+		if keyword == "" {
+			continue
+		}
+
+		switch keyword {
+		case "host":
+			if len(args) == 0 {
+				return fmt.Errorf("%s:%d: Host requires a pattern", file, lineNum)
+			}
+
+			r.blocks = append(r.blocks, sshConfigBlock{
+				patterns: args,
+			})
+
+		case "match":
+			// Match blocks are not supported and never apply.
+			r.blocks = append(r.blocks, sshConfigBlock{
+				match: true,
+			})
+
+		case "include":
+			for _, pattern := range args {
+				if err := r.include(pattern, depth); err != nil {
+					return err
+				}
+			}
+
+		default:
+			if len(args) == 0 {
+				return fmt.Errorf("%s:%d: %s requires a value", file, lineNum, keyword)
+			}
+
+			block := &r.blocks[len(r.blocks)-1]
+			block.options = append(block.options, sshConfigOption{
+				keyword: keyword,
+				value:   strings.Join(args, " "),
+			})
+		}
+	}
+
+	return scanner.Err()
+}
+
+// include will parse the files matching a pattern of an Include.
+// Relative paths are relative to ~/.ssh.
+func (r *sshClientConfig) include(pattern string, depth int) error {
+	pattern, err := homedir.Expand(pattern)
+	if err != nil {
+		return err
+	}
+
+	if !filepath.IsAbs(pattern) {
+		home, err := homedir.Dir()
+		if err != nil {
+			return err
+		}
+
+		pattern = filepath.Join(home, ".ssh", pattern)
+	}
+
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid ssh config include %s: %s", pattern, err)
+	}
+
+	for _, file := range files {
+		if err := r.parseFile(file, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// get returns the first value of an option for a host.
+func (r *sshClientConfig) get(host, keyword string) string {
+	if v := r.getAll(host, keyword); len(v) > 0 {
+		return v[0]
+	}
+
+	return ""
+}
+
+// getAll returns all values of an option for a host in the order
+// they appear in the config.
+func (r *sshClientConfig) getAll(host, keyword string) []string {
+	var values []string
+
+	for _, block := range r.blocks {
+		if !block.matches(host) {
+			continue
+		}
+
+		for _, option := range block.options {
+			if option.keyword == keyword {
+				values = append(values, option.value)
+			}
+		}
+	}
+
+	return values
+}
+
+// matches determines if a Host block applies to a host.
+func (r sshConfigBlock) matches(host string) bool {
+	if r.match {
+		return false
+	}
+
+	host = strings.ToLower(host)
+
+	var matched bool
+	for _, pattern := range r.patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		pattern = strings.ToLower(strings.TrimPrefix(pattern, "!"))
+
+		if !sshPatternRe(pattern).MatchString(host) {
+			continue
+		}
+
+		if negate {
+			return false
+		}
+
+		matched = true
+	}
+
+	return matched
+}
+
+// sshPatternRe converts an ssh_config pattern, which can contain
+// the * and ? wildcards, into a regular expression.
+func sshPatternRe(pattern string) *regexp.Regexp {
+	re := regexp.QuoteMeta(pattern)
+	re = strings.Replace(re, `\*`, ".*", -1)
+	re = strings.Replace(re, `\?`, ".", -1)
+
+	return regexp.MustCompile("^" + re + "$")
+}
+
+// splitSSHConfigLine splits a line of a config file into its lower
+// case keyword and arguments. Arguments can be quoted.
+func splitSSHConfigLine(line string) (string, []string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil
+	}
+
+	// The keyword can be separated from its arguments by "=".
+	i := strings.IndexAny(line, " \t=")
+	if i == -1 {
+		return strings.ToLower(line), nil
+	}
+
+	keyword := strings.ToLower(line[:i])
+	rest := strings.TrimSpace(line[i:])
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, "="))
+
+	var args []string
+	for rest != "" {
+		var arg string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end == -1 {
+				arg, rest = rest[1:], ""
+			} else {
+				arg, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.IndexAny(rest, " \t")
+			if end == -1 {
+				arg, rest = rest, ""
+			} else {
+				arg, rest = rest[:end], rest[end:]
+			}
+		}
+
+		args = append(args, arg)
+		rest = strings.TrimSpace(rest)
+	}
+
+	return keyword, args
+}
+
+// expandSSHTokens will expand the tokens of an ssh_config value:
+// %h is the host, %p the port, %r the remote user, %u the local
+// user, %d the local home directory, and %% a literal %.
+// A leading ~ is also expanded. A port which was not set is the
+// default port, as with ssh.
+func expandSSHTokens(v, host string, port int, remoteUser string) (string, error) {
+	home, _ := homedir.Dir()
+
+	if port == 0 {
+		port = SSHDefaultPort
+	}
+
+	var localUser string
+	if u, err := user.Current(); err == nil {
+		localUser = u.Username
+	}
+
+	tokens := map[byte]string{
+		'h': host,
+		'p': strconv.Itoa(port),
+		'r': remoteUser,
+		'u': localUser,
+		'd': home,
+		'%': "%",
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		if v[i] != '%' {
+			b.WriteByte(v[i])
+			continue
+		}
+
+		if i+1 == len(v) {
+			return "", fmt.Errorf("invalid token in ssh config value: %s", v)
+		}
+
+		t, ok := tokens[v[i+1]]
+		if !ok {
+			return "", fmt.Errorf("unsupported token %%%c in ssh config value: %s", v[i+1], v)
+		}
+
+		b.WriteString(t)
+		i++
+	}
+
+	return homedir.Expand(b.String())
+}
+
+// parseSSHJumpHosts will parse the value of a ProxyJump: a comma
+// separated list of [user@]host[:port]. An IPv6 address with a port
+// is bracketed, as in [::1]:2222.
+func parseSSHJumpHosts(v string) ([]SSHJumpHost, error) {
+	var hosts []SSHJumpHost
+
+	if v == "" || strings.ToLower(v) == "none" {
+		return nil, nil
+	}
+
+	for _, jump := range strings.Split(v, ",") {
+		var host SSHJumpHost
+
+		jump = strings.TrimSpace(jump)
+		if i := strings.LastIndex(jump, "@"); i != -1 {
+			host.User = jump[:i]
+			jump = jump[i+1:]
+		}
+
+		// A host with a single colon, or a bracketed host followed
+		// by a colon, has a port. Any other host, such as an IPv6
+		// address without a port, is only a host.
+		if strings.Count(jump, ":") == 1 || (strings.HasPrefix(jump, "[") && !strings.HasSuffix(jump, "]")) {
+			h, p, err := net.SplitHostPort(jump)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ProxyJump %s: %s", v, err)
+			}
+
+			port, err := strconv.Atoi(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid port in ProxyJump %s: %s", v, err)
+			}
+
+			host.Port = port
+			jump = h
+		}
+
+		jump = strings.Trim(jump, "[]")
+		if jump == "" {
+			return nil, fmt.Errorf("invalid ProxyJump: %s", v)
+		}
+
+		host.Host = jump
+		hosts = append(hosts, host)
+	}
+
+	return hosts, nil
+}
+
+// sshHostKeyCheckingModes maps the values of StrictHostKeyChecking
+// to host key checking modes.
+var sshHostKeyCheckingModes = map[string]string{
+	"yes":        SSHHostKeyCheckingStrict,
+	"ask":        SSHHostKeyCheckingStrict,
+	"accept-new": SSHHostKeyCheckingAcceptNew,
+	"no":         SSHHostKeyCheckingOff,
+	"off":        SSHHostKeyCheckingOff,
+}
+
+// applySSHConfig will apply the options of an OpenSSH client config
+// file to the connection. Options which were set in the connection
+// take precedence over the config file.
+func (r *SSH) applySSHConfig() error {
+	file := r.SSHConfigFile
+	if file == "" {
+		file = SSHDefaultConfigFile
+	}
+
+	file, err := homedir.Expand(file)
+	if err != nil {
+		return err
+	}
+
+	// A missing default config file is not an error.
+	if _, err := os.Stat(file); os.IsNotExist(err) && r.SSHConfigFile == "" {
+		return nil
+	}
+
+	config, err := readSSHClientConfig(file)
+	if err != nil {
+		return err
+	}
+
+	alias := r.Host
+
+	if r.User == "" {
+		r.User = config.get(alias, "user")
+	}
+
+	if r.Port == 0 {
+		if v := config.get(alias, "port"); v != "" {
+			port, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid port for %s in %s: %s", alias, file, v)
+			}
+			r.Port = port
+		}
+	}
+
+	if v := config.get(alias, "hostname"); v != "" {
+		r.Host, err = expandSSHTokens(v, alias, r.Port, r.User)
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.PrivateKey == "" {
+		for _, v := range config.getAll(alias, "identityfile") {
+			key, err := expandSSHTokens(v, alias, r.Port, r.User)
+			if err != nil {
+				return err
+			}
+
+			if _, err := os.Stat(key); err == nil {
+				r.PrivateKey = key
+				break
+			}
+		}
+	}
+
+	if r.HostKeyChecking == "" {
+		if v := config.get(alias, "stricthostkeychecking"); v != "" {
+			mode, ok := sshHostKeyCheckingModes[strings.ToLower(v)]
+			if !ok {
+				return fmt.Errorf("invalid StrictHostKeyChecking for %s in %s: %s", alias, file, v)
+			}
+			r.HostKeyChecking = mode
+		}
+	}
+
+	if r.KnownHostsFile == "" {
+		if v := strings.Fields(config.get(alias, "userknownhostsfile")); len(v) > 0 {
+			r.KnownHostsFile, err = expandSSHTokens(v[0], alias, r.Port, r.User)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// ProxyJump and ProxyCommand are only used when the connection
+	// does not already specify how to reach the host.
+	if r.BastionHost != "" || len(r.JumpHosts) > 0 || r.ProxyCommand != "" {
+		return nil
+	}
+
+	jumpHosts, err := parseSSHJumpHosts(config.get(alias, "proxyjump"))
+	if err != nil {
+		return err
+	}
+
+	for _, jump := range jumpHosts {
+		jump, err := resolveSSHJumpHost(config, jump)
+		if err != nil {
+			return err
+		}
+
+		r.JumpHosts = append(r.JumpHosts, jump)
+	}
+
+	if len(r.JumpHosts) == 0 {
+		if v := config.get(alias, "proxycommand"); v != "" && strings.ToLower(v) != "none" {
+			r.ProxyCommand = v
+		}
+	}
+
+	return nil
+}
+
+// resolveSSHJumpHost will read the options of a ProxyJump host from
+// the config. Options of the ProxyJump itself take precedence.
+func resolveSSHJumpHost(config *sshClientConfig, jump SSHJumpHost) (SSHJumpHost, error) {
+	alias := jump.Host
+
+	if jump.User == "" {
+		jump.User = config.get(alias, "user")
+	}
+
+	if jump.Port == 0 {
+		if v := config.get(alias, "port"); v != "" {
+			port, err := strconv.Atoi(v)
+			if err != nil {
+				return jump, fmt.Errorf("invalid port for %s: %s", alias, v)
+			}
+			jump.Port = port
+		}
+	}
+
+	if v := config.get(alias, "hostname"); v != "" {
+		host, err := expandSSHTokens(v, alias, jump.Port, jump.User)
+		if err != nil {
+			return jump, err
+		}
+		jump.Host = host
+	}
+
+	for _, v := range config.getAll(alias, "identityfile") {
+		key, err := expandSSHTokens(v, alias, jump.Port, jump.User)
+		if err != nil {
+			return jump, err
+		}
+
+		if _, err := os.Stat(key); err == nil {
+			jump.PrivateKey = key
+			break
+		}
+	}
+
+	return jump, nil
+}
@@ -0,0 +1,156 @@
+package connections
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// Host key checking modes.
+const (
+	// SSHHostKeyCheckingStrict only allows hosts with a key in the
+	// known_hosts file.
+	SSHHostKeyCheckingStrict = "strict"
+
+	// SSHHostKeyCheckingAcceptNew adds the keys of unknown hosts to
+	// the known_hosts file, but rejects hosts whose key changed.
+	SSHHostKeyCheckingAcceptNew = "accept-new"
+
+	// SSHHostKeyCheckingOff does not check host keys.
+	SSHHostKeyCheckingOff = "off"
+)
+
+// SSHDefaultKnownHostsFile is the known_hosts file which is used
+// when one was not specified.
+const SSHDefaultKnownHostsFile = "~/.ssh/known_hosts"
+
+// knownHostsMux serializes access to known_hosts files since
+// several hosts can be connected to at the same time.
+var knownHostsMux sync.Mutex
+
+// hostKeyChecker verifies the keys of SSH hosts.
+type hostKeyChecker struct {
+	mode string
+	file string
+
+	// err is the last error which should not be retried,
+	// such as a changed host key.
+	err error
+}
+
+// newHostKeyChecker will return a hostKeyChecker for a mode and
+// known_hosts file.
+func newHostKeyChecker(mode, file string) (*hostKeyChecker, error) {
+	// Unknown hosts are only trusted when asked to.
+	if mode == "" {
+		mode = SSHHostKeyCheckingStrict
+	}
+
+	switch mode {
+	case SSHHostKeyCheckingStrict, SSHHostKeyCheckingAcceptNew, SSHHostKeyCheckingOff:
+	default:
+		return nil, fmt.Errorf("invalid host_key_checking: %s", mode)
+	}
+
+	if file == "" {
+		file = SSHDefaultKnownHostsFile
+	}
+
+	file, err := homedir.Expand(file)
+	if err != nil {
+		return nil, err
+	}
+
+	checker := &hostKeyChecker{
+		mode: mode,
+		file: file,
+	}
+
+	return checker, nil
+}
+
+// callback returns the checker as an ssh.HostKeyCallback.
+func (r *hostKeyChecker) callback() ssh.HostKeyCallback {
+	if r.mode == SSHHostKeyCheckingOff {
+		return ssh.InsecureIgnoreHostKey()
+	}
+
+	return r.check
+}
+
+// check implements ssh.HostKeyCallback.
+func (r *hostKeyChecker) check(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	knownHostsMux.Lock()
+	defer knownHostsMux.Unlock()
+
+	r.err = nil
+
+	if _, err := os.Stat(r.file); err == nil {
+		callback, err := knownhosts.New(r.file)
+		if err != nil {
+			r.err = fmt.Errorf("unable to read known_hosts file %s: %s", r.file, err)
+			return r.err
+		}
+
+		err = callback(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+
+		keyErr, ok := err.(*knownhosts.KeyError)
+		if !ok {
+			r.err = fmt.Errorf("host key of %s was rejected: %s", hostname, err)
+			return r.err
+		}
+
+		if len(keyErr.Want) > 0 {
+			want := keyErr.Want[0]
+			r.err = fmt.Errorf(
+				"host key of %s has changed: got %s %s, but %s:%d has %s %s",
+				hostname, key.Type(), ssh.FingerprintSHA256(key),
+				want.Filename, want.Line, want.Key.Type(), ssh.FingerprintSHA256(want.Key))
+			return r.err
+		}
+	}
+
+	// The host is not known.
+	if r.mode == SSHHostKeyCheckingStrict {
+		r.err = fmt.Errorf("host key of %s is not in %s: %s %s",
+			hostname, r.file, key.Type(), ssh.FingerprintSHA256(key))
+		return r.err
+	}
+
+	if err := r.add(hostname, key); err != nil {
+		r.err = err
+		return r.err
+	}
+
+	return nil
+}
+
+// add will add the key of a host to the known_hosts file.
+func (r *hostKeyChecker) add(hostname string, key ssh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(r.file), 0700); err != nil {
+		return fmt.Errorf("unable to create known_hosts file %s: %s", r.file, err)
+	}
+
+	f, err := os.OpenFile(r.file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to open known_hosts file %s: %s", r.file, err)
+	}
+	defer f.Close()
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		return fmt.Errorf("unable to write to known_hosts file %s: %s", r.file, err)
+	}
+
+	return nil
+}
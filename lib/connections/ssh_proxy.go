@@ -0,0 +1,117 @@
+package connections
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// proxyCommandConn is a net.Conn which sends and receives data
+// through the stdin and stdout of a local command, such as
+// `nc %h %p` or `ssh -W %h:%p bastion`.
+type proxyCommandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+}
+
+// newProxyCommandConn will start a proxy command for a host.
+// The tokens of the command, such as %h, %p, and %r, are expanded
+// in the same way as ssh_config.
+func newProxyCommandConn(command, host string, port int, user string) (*proxyCommandConn, error) {
+	command, err := expandSSHTokens(command, host, port, user)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("unable to start proxy_command %s: %s", command, err)
+	}
+
+	conn := &proxyCommandConn{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: stdout,
+	}
+
+	return conn, nil
+}
+
+// Read implements the Read method of net.Conn.
+func (r *proxyCommandConn) Read(b []byte) (int, error) {
+	return r.stdout.Read(b)
+}
+
+// Write implements the Write method of net.Conn.
+func (r *proxyCommandConn) Write(b []byte) (int, error) {
+	return r.stdin.Write(b)
+}
+
+// Close implements the Close method of net.Conn.
+// It will stop the proxy command.
+func (r *proxyCommandConn) Close() error {
+	r.stdin.Close()
+
+	if r.cmd.Process != nil {
+		r.cmd.Process.Kill()
+	}
+
+	r.cmd.Wait()
+
+	return nil
+}
+
+// LocalAddr implements the LocalAddr method of net.Conn.
+func (r *proxyCommandConn) LocalAddr() net.Addr {
+	return proxyCommandAddr{}
+}
+
+// RemoteAddr implements the RemoteAddr method of net.Conn.
+func (r *proxyCommandConn) RemoteAddr() net.Addr {
+	return proxyCommandAddr{}
+}
+
+// SetDeadline implements the SetDeadline method of net.Conn.
+// Deadlines are not supported.
+func (r *proxyCommandConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+// SetReadDeadline implements the SetReadDeadline method of net.Conn.
+// Deadlines are not supported.
+func (r *proxyCommandConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+// SetWriteDeadline implements the SetWriteDeadline method of net.Conn.
+// Deadlines are not supported.
+func (r *proxyCommandConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// proxyCommandAddr is the address of a proxyCommandConn.
+type proxyCommandAddr struct{}
+
+func (proxyCommandAddr) Network() string {
+	return "proxy_command"
+}
+
+func (proxyCommandAddr) String() string {
+	return "proxy_command"
+}
@@ -0,0 +1,223 @@
+package testing
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jtopjian/yak/lib/connections"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDockerConnection(t *testing.T, server *testDockerServer) connections.Connection {
+	conn, err := connections.New("docker", map[string]interface{}{
+		"host":    "web",
+		"socket":  server.Socket,
+		"timeout": 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+func TestDocker_RunCommand(t *testing.T) {
+	server := newTestDockerServer(t)
+	conn := newTestDockerConnection(t, server)
+	defer conn.Close()
+
+	rr, err := conn.RunCommand(connections.RunOptions{Command: "echo hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "hi", rr.Stdout)
+	assert.Equal(t, 0, rr.ExitCode)
+
+	rr, err = conn.RunCommand(connections.RunOptions{Command: "echo oops >&2; exit 3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "oops", rr.Stderr)
+	assert.Equal(t, 3, rr.ExitCode)
+
+	dir := t.TempDir()
+	ro := connections.RunOptions{
+		Command: `echo "$FOO"; pwd; cat`,
+		Dir:     dir,
+		Env: map[string]string{
+			"FOO": "it's $HOME",
+		},
+		Stdin: strings.NewReader("secret"),
+	}
+
+	rr, err = conn.RunCommand(ro)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "it's $HOME\n"+dir+"\nsecret", rr.Stdout)
+
+	// An invalid env name is an error.
+	ro = connections.RunOptions{
+		Command: "true",
+		Env: map[string]string{
+			"FOO=BAR": "baz",
+		},
+	}
+
+	_, err = conn.RunCommand(ro)
+	if assert.Error(t, err) {
+		assert.Equal(t, "invalid env name: FOO=BAR", err.Error())
+	}
+
+	// Arguments are passed as is.
+	ro = connections.RunOptions{
+		Args: []string{"echo", `"a"`, "$HOME", "it's"},
+	}
+
+	rr, err = conn.RunCommand(ro)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, `"a" $HOME it's`, rr.Stdout)
+
+	// The exit code is waited for if the exec is still running once
+	// its output has closed.
+	server.RunningChecks = 3
+
+	rr, err = conn.RunCommand(connections.RunOptions{Command: "exit 4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 4, rr.ExitCode)
+
+	// An exec which keeps running is an error.
+	server.RunningChecks = 100
+
+	_, err = conn.RunCommand(connections.RunOptions{Command: "true"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "did not exit after 1s")
+	}
+}
+
+func TestDocker_Files(t *testing.T) {
+	server := newTestDockerServer(t)
+	conn := newTestDockerConnection(t, server)
+	defer conn.Close()
+
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "hello.txt")
+
+	// The owner of an uploaded file is set by a command.
+	cfo := connections.CopyFileOptions{
+		Source:      "fixtures/hello.txt",
+		Destination: remote,
+		Mode:        0600,
+		UID:         os.Getuid(),
+		GID:         os.Getgid(),
+	}
+
+	fr, err := conn.FileUpload(cfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, true, fr.Success)
+
+	fr, err = conn.FileInfo(connections.FileOptions{Path: remote})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, true, fr.Exists)
+	assert.Equal(t, "file", fr.FileInfo.Type)
+	assert.Equal(t, 600, fr.FileInfo.Mode)
+	assert.Equal(t, int64(14), fr.FileInfo.Size)
+	assert.Equal(t, os.Getuid(), fr.FileInfo.UID)
+
+	local := filepath.Join(dir, "download.txt")
+	cfo = connections.CopyFileOptions{
+		Source:      remote,
+		Destination: local,
+	}
+
+	fr, err = conn.FileDownload(cfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := ioutil.ReadFile(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, true, fr.Success)
+	assert.Equal(t, "Hello, World!\n", string(actual))
+
+	fr, err = conn.FileDelete(connections.FileOptions{Path: remote})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, true, fr.Success)
+
+	_, err = os.Stat(remote)
+	assert.True(t, os.IsNotExist(err))
+
+	fr, err = conn.FileInfo(connections.FileOptions{Path: remote})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, false, fr.Exists)
+
+	// A missing file can't be downloaded.
+	_, err = conn.FileDownload(cfo)
+	assert.Error(t, err)
+}
+
+func TestDocker_Connect(t *testing.T) {
+	server := newTestDockerServer(t)
+	server.Containers["stopped"] = false
+
+	options := map[string]interface{}{
+		"host":    "missing",
+		"socket":  server.Socket,
+		"timeout": 1,
+	}
+
+	conn, err := connections.New("docker", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = conn.Connect()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "No such container: missing")
+	}
+
+	options["host"] = "stopped"
+	conn, err = connections.New("docker", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Error(t, conn.Connect())
+
+	// A host is required.
+	delete(options, "host")
+	_, err = connections.New("docker", options)
+	assert.Error(t, err)
+}
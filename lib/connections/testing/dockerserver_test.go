@@ -0,0 +1,276 @@
+package testing
+
+import (
+	"archive/tar"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+// testDockerServer is a fake Docker Engine API listening on a unix
+// socket. Commands of every container are run locally and paths of
+// every container are local paths.
+type testDockerServer struct {
+	Socket string
+
+	// Containers are the names of the containers and if they
+	// are running.
+	Containers map[string]bool
+
+	// RunningChecks is the amount of times an exec is reported as
+	// running after its command has exited.
+	RunningChecks int
+
+	execs map[string]*testDockerExec
+	mux   sync.Mutex
+}
+
+// testDockerExec is an exec created through the API.
+type testDockerExec struct {
+	AttachStdin bool
+	Cmd         []string
+	Env         []string
+	WorkingDir  string
+
+	exitCode int
+	checks   int
+}
+
+// newTestDockerServer will start a testDockerServer with a running
+// container named "web". The server is stopped when the test finishes.
+func newTestDockerServer(t *testing.T) *testDockerServer {
+	server := &testDockerServer{
+		Socket:     filepath.Join(t.TempDir(), "docker.sock"),
+		Containers: map[string]bool{"web": true},
+		execs:      make(map[string]*testDockerExec),
+	}
+
+	listener, err := net.Listen("unix", server.Socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(server.handle))
+	ts.Listener = listener
+	ts.Start()
+	t.Cleanup(ts.Close)
+
+	return server
+}
+
+func (r *testDockerServer) handle(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(parts) < 3 || parts[0] != "v1.40" {
+		r.error(w, http.StatusNotFound, "page not found")
+		return
+	}
+
+	switch parts[1] {
+	case "containers":
+		r.mux.Lock()
+		running, ok := r.Containers[parts[2]]
+		r.mux.Unlock()
+
+		if !ok {
+			r.error(w, http.StatusNotFound, "No such container: "+parts[2])
+			return
+		}
+
+		switch {
+		case len(parts) == 4 && parts[3] == "json":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"Name":  "/" + parts[2],
+				"State": map[string]bool{"Running": running},
+			})
+		case len(parts) == 4 && parts[3] == "exec":
+			r.createExec(w, req)
+		case len(parts) == 4 && parts[3] == "archive" && req.Method == "PUT":
+			r.putArchive(w, req)
+		case len(parts) == 4 && parts[3] == "archive" && req.Method == "GET":
+			r.getArchive(w, req)
+		default:
+			r.error(w, http.StatusNotFound, "page not found")
+		}
+	case "exec":
+		r.mux.Lock()
+		e, ok := r.execs[parts[2]]
+		r.mux.Unlock()
+
+		if !ok {
+			r.error(w, http.StatusNotFound, "No such exec instance: "+parts[2])
+			return
+		}
+
+		switch {
+		case len(parts) == 4 && parts[3] == "start":
+			r.startExec(w, req, e)
+		case len(parts) == 4 && parts[3] == "json":
+			r.mux.Lock()
+			exitCode := e.exitCode
+			e.checks++
+			running := e.checks <= r.RunningChecks
+			r.mux.Unlock()
+
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"Running":  running,
+				"ExitCode": exitCode,
+			})
+		default:
+			r.error(w, http.StatusNotFound, "page not found")
+		}
+	default:
+		r.error(w, http.StatusNotFound, "page not found")
+	}
+}
+
+func (r *testDockerServer) error(w http.ResponseWriter, code int, message string) {
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+func (r *testDockerServer) createExec(w http.ResponseWriter, req *http.Request) {
+	var e testDockerExec
+	if err := json.NewDecoder(req.Body).Decode(&e); err != nil {
+		r.error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	r.mux.Lock()
+	id := fmt.Sprintf("exec%d", len(r.execs))
+	r.execs[id] = &e
+	r.mux.Unlock()
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"Id": id})
+}
+
+// startExec will run the command of an exec over a hijacked
+// connection, multiplexing stdout and stderr like the Engine API.
+func (r *testDockerServer) startExec(w http.ResponseWriter, req *http.Request, e *testDockerExec) {
+	io.Copy(ioutil.Discard, req.Body)
+
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		r.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer conn.Close()
+
+	fmt.Fprint(rw, "HTTP/1.1 101 UPGRADED\r\n"+
+		"Content-Type: application/vnd.docker.raw-stream\r\n"+
+		"Connection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	rw.Flush()
+
+	var mux sync.Mutex
+	cmd := exec.Command(e.Cmd[0], e.Cmd[1:]...)
+	cmd.Env = append(os.Environ(), e.Env...)
+	cmd.Dir = e.WorkingDir
+	cmd.Stdout = &testDockerStream{w: conn, stream: 1, mux: &mux}
+	cmd.Stderr = &testDockerStream{w: conn, stream: 2, mux: &mux}
+	if e.AttachStdin {
+		cmd.Stdin = rw
+	}
+
+	var exitCode int
+	if err := cmd.Run(); err != nil {
+		exitCode = 126
+		if exit, ok := err.(*exec.ExitError); ok {
+			exitCode = exit.Sys().(syscall.WaitStatus).ExitStatus()
+		}
+	}
+
+	r.mux.Lock()
+	e.exitCode = exitCode
+	r.mux.Unlock()
+}
+
+func (r *testDockerServer) putArchive(w http.ResponseWriter, req *http.Request) {
+	dir := req.URL.Query().Get("path")
+
+	tr := tar.NewReader(req.Body)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			r.error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		path := filepath.Join(dir, header.Name)
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.FileMode(header.Mode))
+		if err != nil {
+			r.error(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		io.Copy(f, tr)
+		f.Close()
+	}
+}
+
+func (r *testDockerServer) getArchive(w http.ResponseWriter, req *http.Request) {
+	path := req.URL.Query().Get("path")
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		r.error(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	header, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		r.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	tw := tar.NewWriter(w)
+	tw.WriteHeader(header)
+
+	if fi.Mode().IsRegular() {
+		f, err := os.Open(path)
+		if err == nil {
+			io.Copy(tw, f)
+			f.Close()
+		}
+	}
+
+	tw.Close()
+}
+
+// testDockerStream writes frames of a multiplexed exec stream.
+type testDockerStream struct {
+	w      io.Writer
+	stream byte
+	mux    *sync.Mutex
+}
+
+func (r *testDockerStream) Write(p []byte) (int, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	header := make([]byte, 8)
+	header[0] = r.stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(p)))
+
+	if _, err := r.w.Write(append(header, p...)); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
@@ -3,6 +3,7 @@ package testing
 import (
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/jtopjian/yak/lib/connections"
@@ -189,3 +190,63 @@ func TestLocal_FileInfo(t *testing.T) {
 	assert.Equal(t, 644, fr.FileInfo.Mode)
 	assert.Equal(t, int64(14), fr.FileInfo.Size)
 }
+
+func TestLocal_EnvDirStdin(t *testing.T) {
+	local, err := connections.New("local", map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	ro := connections.RunOptions{
+		Command: `echo "$FOO $BAR"; pwd; cat`,
+		Dir:     dir,
+		Env: map[string]string{
+			"FOO": "foo",
+			"BAR": "it's $HOME",
+		},
+		Stdin: strings.NewReader("secret"),
+	}
+
+	rr, err := local.RunCommand(ro)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "foo it's $HOME\n"+dir+"\nsecret", rr.Stdout)
+}
+
+func TestLocal_Quoting(t *testing.T) {
+	local, err := connections.New("local", map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The command is only interpreted once, by the shell.
+	ro := connections.RunOptions{
+		Command: `foo='a "b"'; echo "$foo" 'it'"'"'s' \$foo ` + "`echo c`",
+	}
+
+	rr, err := local.RunCommand(ro)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, `a "b" it's $foo c`, rr.Stdout)
+
+	// Arguments are passed as is.
+	ro = connections.RunOptions{
+		Args: []string{"echo", `"a"`, "$HOME", "`id`", "it's"},
+	}
+
+	rr, err = local.RunCommand(ro)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, `"a" $HOME `+"`id`"+` it's`, rr.Stdout)
+
+	// A command is required.
+	_, err = local.RunCommand(connections.RunOptions{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,139 @@
+package testing
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jtopjian/yak/lib/connections"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testConnection is a connection which counts how often it was
+// connected and closed.
+type testConnection struct {
+	connections.Local
+
+	alive    bool
+	connects int
+	closes   int
+	err      error
+}
+
+func (r *testConnection) Connect() error {
+	r.connects++
+	if r.err != nil {
+		return r.err
+	}
+
+	r.alive = true
+	return nil
+}
+
+func (r *testConnection) Close() {
+	r.closes++
+	r.alive = false
+}
+
+func (r *testConnection) Alive() bool {
+	return r.alive
+}
+
+func TestPool(t *testing.T) {
+	pool := connections.NewPool()
+
+	conn := &testConnection{}
+	for i := 0; i < 3; i++ {
+		c, err := pool.Get("host1", conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, conn, c)
+	}
+
+	assert.Equal(t, 1, conn.connects)
+	assert.Equal(t, 1, pool.Len())
+
+	// A lost connection is reconnected.
+	conn.alive = false
+	if _, err := pool.Get("host1", conn); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 2, conn.connects)
+	assert.Equal(t, 1, conn.closes)
+
+	// A connection which fails is not kept.
+	failed := &testConnection{err: fmt.Errorf("unreachable")}
+	_, err := pool.Get("host2", failed)
+	assert.Error(t, err)
+	assert.Equal(t, 1, pool.Len())
+
+	pool.CloseAll()
+	assert.Equal(t, 2, conn.closes)
+	assert.Equal(t, 0, failed.closes)
+	assert.Equal(t, 0, pool.Len())
+}
+
+func TestPool_SSH(t *testing.T) {
+	server := newTestSSHServer(t)
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+
+	options := testSSHOptions(server, knownHostsFile, "accept-new")
+
+	ssh, err := connections.NewSSH(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := connections.NewPool()
+	defer pool.CloseAll()
+
+	source := filepath.Join(t.TempDir(), "source")
+	if err := ioutil.WriteFile(source, []byte("hi"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Commands and file operations of all steps share a single
+	// connection.
+	for i := 0; i < 3; i++ {
+		conn, err := pool.Get("ssh/host", ssh)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := conn.RunCommand(connections.RunOptions{Command: "true"}); err != nil {
+			t.Fatal(err)
+		}
+
+		destination := filepath.Join(t.TempDir(), "destination")
+		fr, err := conn.FileUpload(connections.CopyFileOptions{
+			Source:      source,
+			Destination: destination,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, fr.Success)
+	}
+
+	assert.Equal(t, 1, server.Connections())
+	assert.True(t, ssh.Alive())
+
+	// A closed connection is reconnected.
+	ssh.Close()
+	assert.False(t, ssh.Alive())
+
+	conn, err := pool.Get("ssh/host", ssh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := conn.RunCommand(connections.RunOptions{Command: "true"}); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 2, server.Connections())
+}
@@ -0,0 +1,209 @@
+package testing
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/jtopjian/yak/lib/connections"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// useTestYakConfig will write a yak config file and use it until the
+// test finishes.
+func useTestYakConfig(t *testing.T, config string) {
+	file := filepath.Join(t.TempDir(), "yak.cfg")
+	if err := ioutil.WriteFile(file, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("YAK_CONFIG_FILE", file)
+	t.Cleanup(func() {
+		os.Unsetenv("YAK_CONFIG_FILE")
+	})
+}
+
+func TestSSH_Password(t *testing.T) {
+	server := newTestSSHServer(t)
+	server.Password = "secret"
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+
+	useTestYakConfig(t, `
+auth:
+  appliance:
+    password: secret
+  wrong:
+    password: wrong
+`)
+
+	options := testSSHOptions(server, knownHostsFile, "accept-new")
+	options["auth"] = "appliance"
+
+	conn, err := connections.New("ssh", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	rr, err := conn.RunCommand(connections.RunOptions{Command: "echo hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "hi", rr.Stdout)
+
+	// Only keyboard-interactive auth is accepted.
+	server.config.PasswordCallback = nil
+
+	conn, err = connections.New("ssh", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A wrong password is rejected.
+	options["auth"] = "wrong"
+	options["timeout"] = 1
+
+	conn, err = connections.New("ssh", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Error(t, conn.Connect())
+
+	// A missing auth entry is an error.
+	options["auth"] = "missing"
+	_, err = connections.New("ssh", options)
+	assert.Error(t, err)
+}
+
+func TestSSH_EncryptedPrivateKey(t *testing.T) {
+	server := newTestSSHServer(t)
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY",
+		x509.MarshalPKCS1PrivateKey(key), []byte("hunter2"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateKey := filepath.Join(t.TempDir(), "id_rsa")
+	if err := ioutil.WriteFile(privateKey, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	useTestYakConfig(t, `
+auth:
+  encrypted:
+    passphrase: hunter2
+`)
+
+	options := testSSHOptions(server, knownHostsFile, "accept-new")
+	options["private_key"] = privateKey
+	options["auth"] = "encrypted"
+
+	conn, err := connections.New("ssh", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a passphrase, and without a terminal to prompt on, the
+	// key can't be read.
+	delete(options, "auth")
+
+	_, err = connections.New("ssh", options)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no passphrase was given")
+	}
+}
+
+func TestSSH_Certificate(t *testing.T) {
+	server := newTestSSHServer(t)
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+
+	ca := newTestSigner(t)
+	server.UserCA = ca.PublicKey()
+
+	// A plain key is rejected.
+	options := testSSHOptions(server, knownHostsFile, "accept-new")
+	options["timeout"] = 1
+
+	conn, err := connections.New("ssh", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Error(t, conn.Connect())
+
+	// The certificate next to the key is used.
+	b, err := ioutil.ReadFile(server.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := &ssh.Certificate{
+		Key:             signer.PublicKey(),
+		CertType:        ssh.UserCert,
+		KeyId:           "yak",
+		ValidPrincipals: []string{"ubuntu"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+
+	certificate := server.PrivateKey + "-cert.pub"
+	if err := ioutil.WriteFile(certificate, ssh.MarshalAuthorizedKey(cert), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err = connections.New("ssh", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A certificate of a different key is an error.
+	options["private_key"] = writeTestPrivateKey(t)
+	options["certificate"] = certificate
+
+	_, err = connections.New("ssh", options)
+	assert.Error(t, err)
+}
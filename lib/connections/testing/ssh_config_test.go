@@ -0,0 +1,240 @@
+package testing
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jtopjian/yak/lib/connections"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSSHConfig = `
+# Options for all hosts.
+ServerAliveInterval 30
+
+Host web* !web9
+  HostName %s
+  Port %d
+  User deploy
+  IdentityFile /does/not/exist
+  IdentityFile %s
+  StrictHostKeyChecking no
+
+Host db.example.com
+  ProxyJump admin@bastion:2222,jump2
+
+Host app.example.com
+  ProxyCommand ssh -W %%h:%%p bastion
+
+Host bastion
+  HostName 10.0.0.1
+  IdentityFile=%s
+
+Host *
+  User fallback
+  Port 22
+`
+
+func writeTestSSHConfig(t *testing.T, server *testSSHServer) string {
+	config := fmt.Sprintf(testSSHConfig,
+		server.Host, server.Port, server.PrivateKey, server.PrivateKey)
+
+	file := filepath.Join(t.TempDir(), "config")
+	if err := ioutil.WriteFile(file, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func TestSSH_SSHConfig(t *testing.T) {
+	server := newTestSSHServer(t)
+	configFile := writeTestSSHConfig(t, server)
+
+	options := map[string]interface{}{
+		"host":            "web1",
+		"ssh_config_file": configFile,
+		"shell":           "/bin/sh",
+		"timeout":         5,
+	}
+
+	conn, err := connections.NewSSH(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, server.Host, conn.Host)
+	assert.Equal(t, server.Port, conn.Port)
+	assert.Equal(t, "deploy", conn.User)
+	assert.Equal(t, server.PrivateKey, conn.PrivateKey)
+	assert.Equal(t, "off", conn.HostKeyChecking)
+	assert.Equal(t, "", conn.BastionHost)
+
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	rr, err := conn.RunCommand(connections.RunOptions{Command: "echo hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "hi", rr.Stdout)
+
+	// Options of the connection take precedence.
+	options["user"] = "root"
+	conn, err = connections.NewSSH(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "root", conn.User)
+
+	// Negated patterns exclude a host from a block.
+	options["host"] = "web9"
+	options["private_key"] = server.PrivateKey
+	conn, err = connections.NewSSH(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "web9", conn.Host)
+	assert.Equal(t, 22, conn.Port)
+}
+
+func TestSSH_SSHConfigProxyJump(t *testing.T) {
+	server := newTestSSHServer(t)
+	configFile := writeTestSSHConfig(t, server)
+
+	options := map[string]interface{}{
+		"host":            "db.example.com",
+		"ssh_config_file": configFile,
+		"private_key":     server.PrivateKey,
+	}
+
+	conn, err := connections.NewSSH(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "db.example.com", conn.Host)
+	assert.Equal(t, "fallback", conn.User)
+	assert.Equal(t, 22, conn.Port)
+	assert.Equal(t, "", conn.ProxyCommand)
+
+	expected := []connections.SSHJumpHost{
+		{Host: "10.0.0.1", Port: 2222, User: "admin", PrivateKey: server.PrivateKey},
+		{Host: "jump2", Port: 22, User: "fallback"},
+	}
+
+	if assert.Equal(t, 2, len(conn.JumpHosts)) {
+		for i, jump := range conn.JumpHosts {
+			assert.Equal(t, expected[i].Host, jump.Host)
+			assert.Equal(t, expected[i].Port, jump.Port)
+			assert.Equal(t, expected[i].User, jump.User)
+			assert.Equal(t, expected[i].PrivateKey, jump.PrivateKey)
+		}
+	}
+
+	// Jump hosts of the connection take precedence.
+	options["bastion_host"] = "10.0.0.2"
+	conn, err = connections.NewSSH(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Equal(t, 1, len(conn.JumpHosts)) {
+		assert.Equal(t, "10.0.0.2", conn.JumpHosts[0].Host)
+	}
+}
+
+func TestSSH_SSHConfigProxyCommand(t *testing.T) {
+	server := newTestSSHServer(t)
+	configFile := writeTestSSHConfig(t, server)
+
+	options := map[string]interface{}{
+		"host":            "app.example.com",
+		"ssh_config_file": configFile,
+		"private_key":     server.PrivateKey,
+	}
+
+	conn, err := connections.NewSSH(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "ssh -W %h:%p bastion", conn.ProxyCommand)
+	assert.Equal(t, 0, len(conn.JumpHosts))
+}
+
+func TestSSH_SSHConfigMissing(t *testing.T) {
+	options := map[string]interface{}{
+		"host":            "web1",
+		"ssh_config_file": "/does/not/exist",
+	}
+
+	_, err := connections.NewSSH(options)
+	assert.Error(t, err)
+}
+
+func TestSSH_SSHConfigTokens(t *testing.T) {
+	server := newTestSSHServer(t)
+	dir := t.TempDir()
+
+	config := `
+Host v6.example.com
+  ProxyJump [::1]:2222,ops@[fe80::1],jump3
+  UserKnownHostsFile ` + dir + `/known_hosts_%h_%p
+
+Host jump3
+  HostName %h.example.com
+  Port 2200
+`
+
+	configFile := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(configFile, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	options := map[string]interface{}{
+		"host":            "v6.example.com",
+		"ssh_config_file": configFile,
+		"private_key":     server.PrivateKey,
+	}
+
+	conn, err := connections.NewSSH(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// %p is the default port if no port was set.
+	assert.Equal(t, filepath.Join(dir, "known_hosts_v6.example.com_22"), conn.KnownHostsFile)
+
+	// IPv6 addresses of jump hosts are bracketed when they have a port,
+	// and the HostName of a jump host is expanded.
+	expected := []connections.SSHJumpHost{
+		{Host: "::1", Port: 2222, User: "root"},
+		{Host: "fe80::1", Port: 22, User: "ops"},
+		{Host: "jump3.example.com", Port: 2200, User: "root"},
+	}
+
+	if assert.Equal(t, 3, len(conn.JumpHosts)) {
+		for i, jump := range conn.JumpHosts {
+			assert.Equal(t, expected[i].Host, jump.Host)
+			assert.Equal(t, expected[i].Port, jump.Port)
+			assert.Equal(t, expected[i].User, jump.User)
+		}
+	}
+
+	// A port must follow a bracketed host.
+	options["host"] = "bad.example.com"
+	if err := ioutil.WriteFile(configFile, []byte("Host bad.example.com\n  ProxyJump [::1]2222\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = connections.NewSSH(options)
+	assert.Error(t, err)
+}
@@ -0,0 +1,103 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jtopjian/yak/lib/actions"
+	"github.com/jtopjian/yak/lib/connections"
+	"github.com/jtopjian/yak/lib/yakfile"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSH_FileCheck(t *testing.T) {
+	server := newTestSSHServer(t)
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+
+	conn, err := connections.New("ssh", testSSHOptions(server, knownHostsFile, "accept-new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	log := logrus.New()
+	log.Out = ioutil.Discard
+	ctx := context.WithValue(context.Background(), "log", logrus.NewEntry(log))
+	check := context.WithValue(ctx, "check", true)
+
+	// The file is larger than a single SFTP packet.
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.txt")
+	destination := filepath.Join(dir, "destination.txt")
+	content := bytes.Repeat([]byte("yak\n"), 20000)
+	if err := ioutil.WriteFile(source, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	upload := yakfile.Step{
+		Name:   "upload",
+		Action: "upload-file",
+		Input: map[string]interface{}{
+			"source":      source,
+			"destination": destination,
+			"mode":        0600,
+			"uid":         os.Getuid(),
+			"gid":         os.Getgid(),
+		},
+	}
+
+	result, err := actions.RunStep(check, conn, upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, result.Changed)
+
+	if _, err := actions.RunStep(ctx, conn, upload); err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := ioutil.ReadFile(destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, content, actual)
+
+	// The owner of an existing file is read from the SFTP server.
+	fr, err := conn.FileInfo(connections.FileOptions{Path: destination})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, os.Getuid(), fr.FileInfo.UID)
+	assert.Equal(t, os.Getgid(), fr.FileInfo.GID)
+
+	result, err = actions.RunStep(check, conn, upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, false, result.Changed)
+
+	remove := yakfile.Step{
+		Name:   "delete",
+		Action: "delete-file",
+		Input: map[string]interface{}{
+			"path": destination,
+		},
+	}
+
+	result, err = actions.RunStep(check, conn, remove)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, result.Changed)
+}
@@ -0,0 +1,192 @@
+package testing
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/jtopjian/yak/lib/connections"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testSSHOptions(server *testSSHServer, knownHostsFile, mode string) map[string]interface{} {
+	return map[string]interface{}{
+		"host":              server.Host,
+		"port":              server.Port,
+		"user":              "ubuntu",
+		"private_key":       server.PrivateKey,
+		"shell":             "/bin/sh",
+		"timeout":           5,
+		"host_key_checking": mode,
+		"known_hosts_file":  knownHostsFile,
+	}
+}
+
+func TestSSH_HostKeyAcceptNew(t *testing.T) {
+	server := newTestSSHServer(t)
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+
+	options := testSSHOptions(server, knownHostsFile, "accept-new")
+	conn, err := connections.New("ssh", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	rr, err := conn.RunCommand(connections.RunOptions{Command: "echo hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "hi", rr.Stdout)
+
+	// The key of the host was added to the known_hosts file.
+	b, err := ioutil.ReadFile(knownHostsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := fmt.Sprintf("%s:%d", server.Host, server.Port)
+	expected := knownhosts.Line([]string{knownhosts.Normalize(addr)}, server.HostKey.PublicKey())
+	assert.Equal(t, expected, strings.TrimSpace(string(b)))
+
+	// Connecting again with strict checking succeeds.
+	options = testSSHOptions(server, knownHostsFile, "strict")
+	conn, err = connections.New("ssh", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSSH_HostKeyStrict(t *testing.T) {
+	server := newTestSSHServer(t)
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+
+	options := testSSHOptions(server, knownHostsFile, "strict")
+	conn, err := connections.New("ssh", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = conn.Connect()
+	if assert.Error(t, err) {
+		fingerprint := ssh.FingerprintSHA256(server.HostKey.PublicKey())
+		assert.Contains(t, err.Error(), "is not in "+knownHostsFile)
+		assert.Contains(t, err.Error(), fingerprint)
+	}
+
+	// Host keys are checked strictly by default.
+	delete(options, "host_key_checking")
+	conn, err = connections.New("ssh", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = conn.Connect()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "is not in "+knownHostsFile)
+	}
+}
+
+func TestSSH_HostKeyMismatch(t *testing.T) {
+	server := newTestSSHServer(t)
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+
+	// Add a different key for the host.
+	addr := fmt.Sprintf("%s:%d", server.Host, server.Port)
+	otherKey := newTestSigner(t).PublicKey()
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, otherKey)
+	if err := ioutil.WriteFile(knownHostsFile, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []string{"strict", "accept-new"} {
+		options := testSSHOptions(server, knownHostsFile, mode)
+		conn, err := connections.New("ssh", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = conn.Connect()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "host key of "+addr+" has changed")
+			assert.Contains(t, err.Error(), ssh.FingerprintSHA256(server.HostKey.PublicKey()))
+			assert.Contains(t, err.Error(), ssh.FingerprintSHA256(otherKey))
+		}
+	}
+
+	// Host keys are not checked when checking is off.
+	options := testSSHOptions(server, knownHostsFile, "off")
+	conn, err := connections.New("ssh", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, conn.Connect())
+}
+
+func TestSSH_HostKeyBastion(t *testing.T) {
+	bastion := newTestSSHServer(t)
+	server := newTestSSHServer(t)
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+
+	options := testSSHOptions(server, knownHostsFile, "accept-new")
+	options["bastion_host"] = bastion.Host
+	options["bastion_port"] = bastion.Port
+	options["bastion_user"] = "ubuntu"
+	options["bastion_private_key"] = bastion.PrivateKey
+
+	conn, err := connections.New("ssh", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	rr, err := conn.RunCommand(connections.RunOptions{Command: "echo hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "hi", rr.Stdout)
+
+	// Both the bastion and the host were added.
+	b, err := ioutil.ReadFile(knownHostsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 2, len(strings.Split(strings.TrimSpace(string(b)), "\n")))
+
+	// The bastion's key is checked, too.
+	bastionAddr := fmt.Sprintf("%s:%d", bastion.Host, bastion.Port)
+	line := knownhosts.Line([]string{knownhosts.Normalize(bastionAddr)}, server.HostKey.PublicKey())
+	if err := ioutil.WriteFile(knownHostsFile, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err = connections.New("ssh", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = conn.Connect()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "host key of "+bastionAddr+" has changed")
+	}
+}
@@ -0,0 +1,240 @@
+package testing
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jtopjian/yak/lib/connections"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSH_JumpHosts(t *testing.T) {
+	jump1 := newTestSSHServer(t)
+	jump2 := newTestSSHServer(t)
+	server := newTestSSHServer(t)
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+
+	// Jump hosts are usually read from YAML.
+	options := testSSHOptions(server, knownHostsFile, "accept-new")
+	options["jump_hosts"] = []interface{}{
+		map[interface{}]interface{}{
+			"host":        jump1.Host,
+			"port":        jump1.Port,
+			"user":        "jump1",
+			"private_key": jump1.PrivateKey,
+		},
+		map[interface{}]interface{}{
+			"host": jump2.Host,
+			"port": jump2.Port,
+		},
+	}
+
+	conn, err := connections.NewSSH(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 2, len(conn.JumpHosts))
+	assert.Equal(t, "jump1", conn.JumpHosts[0].User)
+	assert.Equal(t, "root", conn.JumpHosts[1].User)
+
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	rr, err := conn.RunCommand(connections.RunOptions{Command: "echo hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "hi", rr.Stdout)
+
+	// A bastion host is the first jump host.
+	options["bastion_host"] = jump1.Host
+	options["bastion_port"] = jump1.Port
+	options["jump_hosts"] = []interface{}{
+		map[interface{}]interface{}{
+			"host": jump2.Host,
+			"port": jump2.Port,
+		},
+	}
+
+	conn, err = connections.NewSSH(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 2, len(conn.JumpHosts))
+	assert.Equal(t, jump1.Port, conn.JumpHosts[0].Port)
+	assert.Equal(t, jump2.Port, conn.JumpHosts[1].Port)
+
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+}
+
+func TestSSH_JumpHostAuth(t *testing.T) {
+	jump := newTestSSHServer(t)
+	jump.Password = "jump-secret"
+	server := newTestSSHServer(t)
+	server.Password = "secret"
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+
+	useTestYakConfig(t, `
+auth:
+  host:
+    password: secret
+  jump:
+    password: jump-secret
+`)
+
+	// The password of the host is not sent to a jump host.
+	options := testSSHOptions(server, knownHostsFile, "accept-new")
+	options["auth"] = "host"
+	options["timeout"] = 5
+	options["jump_hosts"] = []interface{}{
+		map[interface{}]interface{}{
+			"host": jump.Host,
+			"port": jump.Port,
+		},
+	}
+
+	conn, err := connections.NewSSH(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Error(t, conn.Connect())
+
+	// A jump host uses its own auth entry.
+	options["jump_hosts"] = []interface{}{
+		map[interface{}]interface{}{
+			"host":  jump.Host,
+			"port":  jump.Port,
+			"auth":  "jump",
+			"agent": false,
+		},
+	}
+
+	conn, err = connections.NewSSH(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	rr, err := conn.RunCommand(connections.RunOptions{Command: "echo hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "hi", rr.Stdout)
+
+	// An unknown auth entry is an error.
+	options["jump_hosts"] = []interface{}{
+		map[interface{}]interface{}{
+			"host": jump.Host,
+			"port": jump.Port,
+			"auth": "missing",
+		},
+	}
+
+	_, err = connections.NewSSH(options)
+	assert.Error(t, err)
+}
+
+func TestSSH_JumpHostUnreachable(t *testing.T) {
+	server := newTestSSHServer(t)
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+
+	// Find a port which nothing listens on.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+	listener.Close()
+
+	options := testSSHOptions(server, knownHostsFile, "accept-new")
+	options["timeout"] = 1
+	options["jump_hosts"] = []interface{}{
+		map[string]interface{}{
+			"host": addr.IP.String(),
+			"port": addr.Port,
+		},
+	}
+
+	conn, err := connections.New("ssh", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Error(t, conn.Connect())
+}
+
+func TestSSH_ProxyCommand(t *testing.T) {
+	server := newTestSSHServer(t)
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+
+	options := testSSHOptions(server, knownHostsFile, "accept-new")
+	options["proxy_command"] = fmt.Sprintf(
+		"YAK_TEST_PROXY_COMMAND=1 %s -test.run=TestHelperProxyCommand -- %%h %%p", os.Args[0])
+
+	conn, err := connections.New("ssh", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	rr, err := conn.RunCommand(connections.RunOptions{Command: "echo hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "hi", rr.Stdout)
+}
+
+// TestHelperProxyCommand isn't a real test. It is run as the
+// proxy_command of TestSSH_ProxyCommand and copies stdin and stdout
+// to the host and port it was given, like `nc %h %p`.
+func TestHelperProxyCommand(t *testing.T) {
+	if os.Getenv("YAK_TEST_PROXY_COMMAND") != "1" {
+		return
+	}
+
+	args := os.Args
+	for i, arg := range args {
+		if arg == "--" {
+			args = args[i+1:]
+			break
+		}
+	}
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(args[0], args[1]))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	go func() {
+		io.Copy(conn, os.Stdin)
+		conn.Close()
+	}()
+
+	io.Copy(os.Stdout, conn)
+	os.Exit(0)
+}
@@ -0,0 +1,141 @@
+package testing
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jtopjian/yak/lib/connections"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSH_EnvDirStdin(t *testing.T) {
+	server := newTestSSHServer(t)
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+
+	conn, err := connections.New("ssh", testSSHOptions(server, knownHostsFile, "accept-new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	ro := connections.RunOptions{
+		Command: `echo "$FOO"; pwd; cat`,
+		Dir:     dir,
+		Env: map[string]string{
+			"FOO": "foo bar",
+		},
+		Stdin: strings.NewReader("secret"),
+	}
+
+	rr, err := conn.RunCommand(ro)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "foo bar\n"+dir+"\nsecret", rr.Stdout)
+
+	// A missing directory is an error.
+	ro = connections.RunOptions{
+		Command: "echo hi",
+		Dir:     filepath.Join(dir, "missing"),
+	}
+
+	rr, err = conn.RunCommand(ro)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, rr.ExitCode)
+	assert.Equal(t, "", rr.Stdout)
+}
+
+func TestSSH_Quoting(t *testing.T) {
+	server := newTestSSHServer(t)
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+
+	conn, err := connections.New("ssh", testSSHOptions(server, knownHostsFile, "accept-new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The command is only interpreted once, by the shell.
+	ro := connections.RunOptions{
+		Command: `foo='a "b"'; echo "$foo" 'it'"'"'s' \$foo ` + "`echo c`",
+	}
+
+	rr, err := conn.RunCommand(ro)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, `a "b" it's $foo c`, rr.Stdout)
+
+	// Arguments are passed as is.
+	ro = connections.RunOptions{
+		Args: []string{"echo", `"a"`, "$HOME", "`id`", "it's"},
+	}
+
+	rr, err = conn.RunCommand(ro)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, `"a" $HOME `+"`id`"+` it's`, rr.Stdout)
+
+	// A command is required.
+	_, err = conn.RunCommand(connections.RunOptions{})
+	assert.Error(t, err)
+}
+
+func TestSSH_IPv6(t *testing.T) {
+	listener, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 is not available: %s", err)
+	}
+
+	jump := newTestSSHServerWithListener(t, listener)
+
+	listener, err = net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := newTestSSHServerWithListener(t, listener)
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+
+	// Targets bracket IPv6 addresses.
+	options := testSSHOptions(server, knownHostsFile, "accept-new")
+	options["host"] = "[::1]"
+	options["bastion_host"] = "[::1]"
+	options["bastion_port"] = jump.Port
+
+	conn, err := connections.New("ssh", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	rr, err := conn.RunCommand(connections.RunOptions{Command: "echo hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "hi", rr.Stdout)
+}
@@ -13,11 +13,12 @@ func TestSSH_Basic(t *testing.T) {
 	config := &yakfile.Connection{
 		Type: "ssh",
 		Options: map[string]interface{}{
-			"host":        "localhost",
-			"user":        "ubuntu",
-			"private_key": "/root/.ssh/id_rsa",
-			"shell":       "/bin/bash",
-			"timeout":     5,
+			"host":              "localhost",
+			"user":              "ubuntu",
+			"private_key":       "/root/.ssh/id_rsa",
+			"shell":             "/bin/bash",
+			"host_key_checking": "accept-new",
+			"timeout":           5,
 		},
 	}
 
@@ -49,7 +50,7 @@ func TestSSH_Basic(t *testing.T) {
 
 	assert.Equal(t, "/bin/bash: asdf: command not found", rr.Stderr)
 
-	ro.Command = `foo=bar; sleep 1; echo foobar >&2; echo \$foo ; echo 123 >&2`
+	ro.Command = `foo=bar; sleep 1; echo foobar >&2; echo $foo ; echo 123 >&2`
 	rr, err = ssh.RunCommand(ro)
 	if err != nil {
 		t.Fatal(err)
@@ -63,10 +64,11 @@ func TestSSH_CommandTimeout(t *testing.T) {
 	config := &yakfile.Connection{
 		Type: "ssh",
 		Options: map[string]interface{}{
-			"host":        "localhost",
-			"user":        "ubuntu",
-			"private_key": "/root/.ssh/id_rsa",
-			"shell":       "/bin/bash",
+			"host":              "localhost",
+			"user":              "ubuntu",
+			"private_key":       "/root/.ssh/id_rsa",
+			"shell":             "/bin/bash",
+			"host_key_checking": "accept-new",
 		},
 	}
 
@@ -92,11 +94,12 @@ func TestSSH_ConnectTimeout(t *testing.T) {
 	config := &yakfile.Connection{
 		Type: "ssh",
 		Options: map[string]interface{}{
-			"host":        "localhost2",
-			"user":        "ubuntu",
-			"private_key": "/root/.ssh/id_rsa",
-			"shell":       "/bin/bash",
-			"timeout":     5,
+			"host":              "localhost2",
+			"user":              "ubuntu",
+			"private_key":       "/root/.ssh/id_rsa",
+			"shell":             "/bin/bash",
+			"host_key_checking": "accept-new",
+			"timeout":           5,
 		},
 	}
 
@@ -117,6 +120,7 @@ func TestSSH_Bastion(t *testing.T) {
 			"user":                "ubuntu",
 			"private_key":         "/root/.ssh/id_rsa",
 			"shell":               "/bin/bash",
+			"host_key_checking":   "accept-new",
 			"timeout":             5,
 			"bastion_host":        "localhost",
 			"bastion_user":        "ubuntu",
@@ -149,10 +153,11 @@ func TestSSH_CopyFileDelete(t *testing.T) {
 	config := &yakfile.Connection{
 		Type: "ssh",
 		Options: map[string]interface{}{
-			"host":        "localhost",
-			"user":        "ubuntu",
-			"private_key": "/root/.ssh/id_rsa",
-			"shell":       "/bin/bash",
+			"host":              "localhost",
+			"user":              "ubuntu",
+			"private_key":       "/root/.ssh/id_rsa",
+			"shell":             "/bin/bash",
+			"host_key_checking": "accept-new",
 		},
 	}
 
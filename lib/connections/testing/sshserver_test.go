@@ -0,0 +1,306 @@
+package testing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/pkg/sftp"
+)
+
+// testSSHServer is an in-process SSH server which runs commands
+// locally. It accepts any public key unless Password or UserCA are
+// set.
+type testSSHServer struct {
+	Host    string
+	Port    int
+	HostKey ssh.Signer
+
+	// PrivateKey is the path to a private key a client can use.
+	PrivateKey string
+
+	// Password is the only password which is accepted, by both
+	// password and keyboard-interactive auth. Public keys are
+	// rejected when it is set.
+	Password string
+
+	// UserCA is the only authority whose certificates are accepted.
+	// Plain public keys are rejected when it is set.
+	UserCA ssh.PublicKey
+
+	// connections is the amount of connections which were accepted.
+	connections int32
+
+	listener net.Listener
+	config   *ssh.ServerConfig
+}
+
+// newTestSSHServer will start a testSSHServer on a random port.
+// The server is stopped when the test finishes.
+func newTestSSHServer(t *testing.T) *testSSHServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newTestSSHServerWithListener(t, listener)
+}
+
+// newTestSSHServerWithListener will start a testSSHServer on a
+// listener, such as one of an IPv6 address.
+func newTestSSHServerWithListener(t *testing.T, listener net.Listener) *testSSHServer {
+	hostKey := newTestSigner(t)
+
+	config := &ssh.ServerConfig{}
+	config.AddHostKey(hostKey)
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+
+	server := &testSSHServer{
+		Host:       host,
+		Port:       p,
+		HostKey:    hostKey,
+		PrivateKey: writeTestPrivateKey(t),
+		listener:   listener,
+		config:     config,
+	}
+
+	config.PublicKeyCallback = server.publicKeyCallback
+	config.PasswordCallback = server.passwordCallback
+	config.KeyboardInteractiveCallback = server.keyboardInteractiveCallback
+
+	go server.serve()
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	return server
+}
+
+// publicKeyCallback authenticates a client by public key.
+func (r *testSSHServer) publicKeyCallback(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	if r.Password != "" {
+		return nil, fmt.Errorf("public keys are not accepted")
+	}
+
+	if r.UserCA != nil {
+		checker := &ssh.CertChecker{
+			IsUserAuthority: func(auth ssh.PublicKey) bool {
+				return bytes.Equal(auth.Marshal(), r.UserCA.Marshal())
+			},
+		}
+
+		return checker.Authenticate(c, key)
+	}
+
+	return nil, nil
+}
+
+// passwordCallback authenticates a client by password.
+func (r *testSSHServer) passwordCallback(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+	if r.Password == "" || string(password) != r.Password {
+		return nil, fmt.Errorf("invalid password")
+	}
+
+	return nil, nil
+}
+
+// keyboardInteractiveCallback authenticates a client by asking for
+// a password.
+func (r *testSSHServer) keyboardInteractiveCallback(c ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+	answers, err := client(c.User(), "", []string{"Password: "}, []bool{false})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.passwordCallback(c, []byte(answers[0]))
+}
+
+// serve accepts connections until the listener is closed.
+func (r *testSSHServer) serve() {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		atomic.AddInt32(&r.connections, 1)
+		go r.handle(conn)
+	}
+}
+
+// Connections returns the amount of connections which were accepted.
+func (r *testSSHServer) Connections() int {
+	return int(atomic.LoadInt32(&r.connections))
+}
+
+// handle handles a single client connection.
+func (r *testSSHServer) handle(conn net.Conn) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, r.config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		switch newChannel.ChannelType() {
+		case "session":
+			go r.session(newChannel)
+		case "direct-tcpip":
+			go r.forward(newChannel)
+		default:
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+		}
+	}
+}
+
+// session runs the command of an exec request or serves the sftp
+// subsystem.
+func (r *testSSHServer) session(newChannel ssh.NewChannel) {
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	for req := range reqs {
+		if req.Type == "subsystem" {
+			var payload struct{ Name string }
+			ssh.Unmarshal(req.Payload, &payload)
+			if payload.Name != "sftp" {
+				req.Reply(false, nil)
+				continue
+			}
+
+			req.Reply(true, nil)
+			go ssh.DiscardRequests(reqs)
+
+			server, err := sftp.NewServer(channel)
+			if err != nil {
+				return
+			}
+			server.Serve()
+
+			return
+		}
+
+		if req.Type != "exec" {
+			req.Reply(false, nil)
+			continue
+		}
+
+		var payload struct{ Command string }
+		ssh.Unmarshal(req.Payload, &payload)
+		req.Reply(true, nil)
+
+		cmd := exec.Command("/bin/sh", "-c", payload.Command)
+		cmd.Stdin = channel
+		cmd.Stdout = channel
+		cmd.Stderr = channel.Stderr()
+
+		var status uint32
+		if err := cmd.Run(); err != nil {
+			status = 1
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				status = uint32(exitErr.Sys().(syscall.WaitStatus).ExitStatus())
+			}
+		}
+
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, status)
+		channel.SendRequest("exit-status", false, b)
+
+		return
+	}
+}
+
+// forward forwards a direct-tcpip channel so the server can be
+// used as a bastion host.
+func (r *testSSHServer) forward(newChannel ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	addr := net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port)))
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	go func() {
+		io.Copy(channel, conn)
+		channel.Close()
+	}()
+
+	io.Copy(conn, channel)
+	conn.Close()
+}
+
+// newTestSigner will generate a new RSA key.
+func newTestSigner(t *testing.T) ssh.Signer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signer
+}
+
+// writeTestPrivateKey will generate a new RSA key and write it to
+// a temporary file.
+func writeTestPrivateKey(t *testing.T) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+
+	file := filepath.Join(t.TempDir(), "id_rsa")
+	if err := ioutil.WriteFile(file, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sort"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -15,6 +18,95 @@ const (
 	maxBackoffDelay     = 10 * time.Second
 )
 
+// sortedKeys returns the keys of a map in order.
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// statFile will get information about a file by running stat on a
+// connection. It is used by drivers whose API can't describe files.
+func statFile(conn Connection, fo FileOptions) (*FileResult, error) {
+	var fr FileResult
+
+	// validate options
+	if fo.Path == "" {
+		return nil, fmt.Errorf("path is required for file exists")
+	}
+
+	ro := RunOptions{
+		Args:    []string{"stat", "-c%u:%g:%n:%s:%a:%F", fo.Path},
+		Timeout: fo.Timeout,
+	}
+
+	rr, err := conn.RunCommand(ro)
+	if err != nil {
+		return &fr, err
+	}
+
+	if rr.ExitCode != 0 {
+		fr.Exists = false
+		fr.Success = true
+		return &fr, nil
+	}
+
+	parts := strings.Split(rr.Stdout, ":")
+	if len(parts) != 6 {
+		return nil, fmt.Errorf("unable to get file information for %s", fo.Path)
+	}
+
+	uid, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("unable to get file information for %s", fo.Path)
+	}
+
+	gid, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("unable to get file information for %s", fo.Path)
+	}
+
+	size, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get file information for %s", fo.Path)
+	}
+
+	mode, err := strconv.Atoi(parts[4])
+	if err != nil {
+		return nil, fmt.Errorf("unable to get file information for %s", fo.Path)
+	}
+
+	fi := FileInfo{
+		UID:  uid,
+		GID:  gid,
+		Name: parts[2],
+		Size: size,
+		Mode: mode,
+	}
+
+	switch parts[5] {
+	case "regular file":
+		fi.Type = "file"
+	case "directory":
+		fi.Type = "directory"
+	case "symbolic link":
+		fi.Type = "symlink"
+	case "socket":
+		fi.Type = "socket"
+	}
+
+	fr.FileInfo = fi
+	fr.Exists = true
+	fr.Success = true
+	fr.Applied = true
+
+	return &fr, nil
+}
+
 // Based off of Terraform's remote and local provisioners.
 func printOutput(output io.Writer, r io.Reader, doneCh chan<- struct{}) {
 	defer close(doneCh)
@@ -65,10 +157,21 @@ func timeoutFunc(timeout int, f func() error) error {
 	return nil
 }
 
+// stopRetry wraps an error which should stop retryFunc from
+// retrying a function.
+type stopRetry struct {
+	err error
+}
+
+func (e stopRetry) Error() string {
+	return e.err.Error()
+}
+
 // Again, based off of Terraform's remote-exec provisioner.
 // This will retry a function several times until a timeout
 // is reached. Each attempt of the function will be delayed
-// incrementally.
+// incrementally. If the function returns a stopRetry error,
+// the error is returned without retrying.
 func retryFunc(timeout int, f func() error) error {
 	t := time.Duration(timeout) * time.Second
 	ctx, cancel := context.WithTimeout(context.Background(), t)
@@ -99,6 +202,10 @@ func retryFunc(timeout int, f func() error) error {
 				return
 			}
 
+			if _, ok := err.(stopRetry); ok {
+				return
+			}
+
 			delay *= 2
 			if delay == 0 {
 				delay = initialBackoffDelay
@@ -123,6 +230,10 @@ func retryFunc(timeout int, f func() error) error {
 	}
 
 	if ev, ok := errVal.Load().(*errWrap); ok {
+		if e, ok := ev.E.(stopRetry); ok {
+			return e.err
+		}
+
 		return ev.E
 	}
 
@@ -0,0 +1,278 @@
+package facts
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jtopjian/yak/lib/connections"
+)
+
+// FactsCommandTimeout is the amount of time the fact gathering
+// command can run.
+const FactsCommandTimeout = 60
+
+// sectionPrefix marks the beginning of a section in the output of
+// factsScript.
+const sectionPrefix = "==> "
+
+// factsScript is run on a host to gather all facts at once.
+// Each section of the output is parsed separately. The script must
+// not contain double quotes or variables since it is passed to the
+// remote shell as-is by some connection drivers.
+const factsScript = `
+echo '==> os-release'; cat /etc/os-release 2>/dev/null
+echo '==> kernel'; uname -r 2>/dev/null
+echo '==> architecture'; uname -m 2>/dev/null
+echo '==> cpus'; getconf _NPROCESSORS_ONLN 2>/dev/null || grep -c ^processor /proc/cpuinfo 2>/dev/null
+echo '==> meminfo'; cat /proc/meminfo 2>/dev/null
+echo '==> links'; ip -o link show 2>/dev/null
+echo '==> addresses'; ip -o addr show 2>/dev/null
+echo '==> package-managers'; command -v apt-get dnf yum zypper apk pacman 2>/dev/null
+echo '==> init'; if [ -d /run/systemd/system ]; then echo systemd; else cat /proc/1/comm 2>/dev/null; fi
+true
+`
+
+// Facts represents information about a host.
+type Facts struct {
+	OS             OS          `json:"os"`
+	Kernel         string      `json:"kernel"`
+	Architecture   string      `json:"architecture"`
+	CPUs           int         `json:"cpus"`
+	MemoryMB       int64       `json:"memory_mb"`
+	Interfaces     []Interface `json:"interfaces"`
+	PackageManager string      `json:"package_manager"`
+	InitSystem     string      `json:"init_system"`
+}
+
+// OS represents the operating system release of a host.
+// It is read from /etc/os-release.
+type OS struct {
+	ID         string `json:"id"`
+	IDLike     string `json:"id_like"`
+	Name       string `json:"name"`
+	PrettyName string `json:"pretty_name"`
+	Version    string `json:"version"`
+	VersionID  string `json:"version_id"`
+	Codename   string `json:"codename"`
+}
+
+// Interface represents a network interface of a host.
+type Interface struct {
+	Name      string   `json:"name"`
+	MAC       string   `json:"mac"`
+	Addresses []string `json:"addresses"`
+}
+
+// Data returns the facts for use in the templated input of a step.
+func (r Facts) Data() map[string]interface{} {
+	data := make(map[string]interface{})
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return data
+	}
+
+	json.Unmarshal(b, &data)
+
+	return data
+}
+
+// Gather will gather the facts of a host through a connection.
+func Gather(conn connections.Connection) (*Facts, error) {
+	ro := connections.RunOptions{
+		Command: factsScript,
+		Timeout: FactsCommandTimeout,
+	}
+
+	rr, err := conn.RunCommand(ro)
+	if err != nil {
+		return nil, fmt.Errorf("unable to gather facts: %s", err)
+	}
+
+	if rr.ExitCode != 0 {
+		return nil, fmt.Errorf("unable to gather facts: %s", rr.Stderr)
+	}
+
+	return Parse(rr.Stdout), nil
+}
+
+// Parse will parse the output of the fact gathering command.
+func Parse(output string) *Facts {
+	var facts Facts
+
+	sections := parseSections(output)
+
+	facts.OS = parseOSRelease(sections["os-release"])
+	facts.Kernel = strings.TrimSpace(sections["kernel"])
+	facts.Architecture = strings.TrimSpace(sections["architecture"])
+	facts.CPUs, _ = strconv.Atoi(strings.TrimSpace(sections["cpus"]))
+	facts.MemoryMB = parseMeminfo(sections["meminfo"])
+	facts.Interfaces = parseInterfaces(sections["links"], sections["addresses"])
+	facts.InitSystem = strings.TrimSpace(sections["init"])
+
+	// The package managers are listed in order of preference.
+	if v := strings.Fields(sections["package-managers"]); len(v) > 0 {
+		facts.PackageManager = path.Base(v[0])
+	}
+
+	return &facts
+}
+
+// parseSections will split the output of the fact gathering command
+// into its sections.
+func parseSections(output string) map[string]string {
+	sections := make(map[string]string)
+
+	var name string
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, sectionPrefix) {
+			if name != "" {
+				sections[name] = strings.Join(lines, "\n")
+			}
+
+			name = strings.TrimPrefix(line, sectionPrefix)
+			lines = nil
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	if name != "" {
+		sections[name] = strings.Join(lines, "\n")
+	}
+
+	return sections
+}
+
+// parseOSRelease will parse the contents of /etc/os-release.
+func parseOSRelease(v string) OS {
+	var os OS
+
+	values := make(map[string]string)
+	for _, line := range strings.Split(v, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) != 2 || strings.HasPrefix(parts[0], "#") {
+			continue
+		}
+
+		values[parts[0]] = strings.Trim(parts[1], `"'`)
+	}
+
+	os.ID = values["ID"]
+	os.IDLike = values["ID_LIKE"]
+	os.Name = values["NAME"]
+	os.PrettyName = values["PRETTY_NAME"]
+	os.Version = values["VERSION"]
+	os.VersionID = values["VERSION_ID"]
+
+	os.Codename = values["VERSION_CODENAME"]
+	if os.Codename == "" {
+		os.Codename = values["UBUNTU_CODENAME"]
+	}
+
+	return os
+}
+
+// meminfoTotalRe matches the total memory in /proc/meminfo.
+var meminfoTotalRe = regexp.MustCompile(`MemTotal:\s+(\d+) kB`)
+
+// parseMeminfo will return the total memory in MB from /proc/meminfo.
+func parseMeminfo(v string) int64 {
+	if m := meminfoTotalRe.FindStringSubmatch(v); m != nil {
+		kb, err := strconv.ParseInt(m[1], 10, 64)
+		if err == nil {
+			return kb / 1024
+		}
+	}
+
+	return 0
+}
+
+// linkRe matches a line of `ip -o link show`.
+var linkRe = regexp.MustCompile(`^\d+: ([^:@]+)[@:].*link/\w+ ([0-9a-f:]+)`)
+
+// addressRe matches a line of `ip -o addr show`.
+var addressRe = regexp.MustCompile(`^\d+: (\S+)\s+inet6? (\S+)`)
+
+// parseInterfaces will parse the output of `ip -o link show` and
+// `ip -o addr show`.
+func parseInterfaces(links, addresses string) []Interface {
+	var interfaces []Interface
+	index := make(map[string]int)
+
+	for _, line := range strings.Split(links, "\n") {
+		if m := linkRe.FindStringSubmatch(line); m != nil {
+			index[m[1]] = len(interfaces)
+			interfaces = append(interfaces, Interface{
+				Name: m[1],
+				MAC:  m[2],
+			})
+		}
+	}
+
+	for _, line := range strings.Split(addresses, "\n") {
+		m := addressRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		i, ok := index[m[1]]
+		if !ok {
+			i = len(interfaces)
+			index[m[1]] = i
+			interfaces = append(interfaces, Interface{Name: m[1]})
+		}
+
+		interfaces[i].Addresses = append(interfaces[i].Addresses, m[2])
+	}
+
+	return interfaces
+}
+
+// Cache holds the facts of hosts for the duration of a run.
+// Facts are only gathered once per host.
+type Cache struct {
+	entries map[string]*cacheEntry
+	mux     sync.Mutex
+}
+
+type cacheEntry struct {
+	facts *Facts
+	err   error
+	once  sync.Once
+}
+
+// NewCache will return an empty Cache.
+func NewCache() *Cache {
+	return &Cache{
+		entries: make(map[string]*cacheEntry),
+	}
+}
+
+// Get will return the facts of a host. If the facts have not been
+// gathered yet, they are gathered through the given connection.
+func (r *Cache) Get(key string, conn connections.Connection) (*Facts, error) {
+	r.mux.Lock()
+	entry, ok := r.entries[key]
+	if !ok {
+		entry = &cacheEntry{}
+		r.entries[key] = entry
+	}
+	r.mux.Unlock()
+
+	entry.once.Do(func() {
+		entry.facts, entry.err = Gather(conn)
+	})
+
+	return entry.facts, entry.err
+}
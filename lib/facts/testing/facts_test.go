@@ -0,0 +1,79 @@
+package testing
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/jtopjian/yak/lib/connections"
+	"github.com/jtopjian/yak/lib/facts"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFacts_Parse(t *testing.T) {
+	output, err := ioutil.ReadFile("fixtures/ubuntu.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &facts.Facts{
+		OS: facts.OS{
+			ID:         "ubuntu",
+			IDLike:     "debian",
+			Name:       "Ubuntu",
+			PrettyName: "Ubuntu 16.04.3 LTS",
+			Version:    "16.04.3 LTS (Xenial Xerus)",
+			VersionID:  "16.04",
+			Codename:   "xenial",
+		},
+		Kernel:       "4.4.0-104-generic",
+		Architecture: "x86_64",
+		CPUs:         4,
+		MemoryMB:     7983,
+		Interfaces: []facts.Interface{
+			facts.Interface{
+				Name:      "lo",
+				MAC:       "00:00:00:00:00:00",
+				Addresses: []string{"127.0.0.1/8", "::1/128"},
+			},
+			facts.Interface{
+				Name:      "eth0",
+				MAC:       "00:16:3e:5f:1a:2b",
+				Addresses: []string{"10.0.3.15/24", "fe80::216:3eff:fe5f:1a2b/64"},
+			},
+		},
+		PackageManager: "apt-get",
+		InitSystem:     "systemd",
+	}
+
+	actual := facts.Parse(string(output))
+	assert.Equal(t, expected, actual)
+
+	data := actual.Data()
+	assert.Equal(t, "ubuntu", data["os"].(map[string]interface{})["id"])
+}
+
+func TestFacts_GatherLocal(t *testing.T) {
+	local, err := connections.New("local", map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := facts.NewCache()
+
+	f, err := cache.Get("local", local)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEqual(t, "", f.Kernel)
+	assert.NotEqual(t, "", f.Architecture)
+
+	// The cached facts are returned on subsequent calls.
+	cached, err := cache.Get("local", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, f, cached)
+}
@@ -0,0 +1,416 @@
+package shared
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DockerDefaultSocket = "/var/run/docker.sock"
+
+	// DockerAPIVersion is the version of the Engine API which is used.
+	// Podman serves a compatible version of the API.
+	DockerAPIVersion = "v1.40"
+
+	// dockerExecPollInterval is how often an exec is inspected while
+	// waiting for it to exit.
+	dockerExecPollInterval = 100 * time.Millisecond
+)
+
+// DockerClient is a client of the Docker Engine API. It talks to the
+// API over a unix socket, so it works with both Docker and Podman.
+type DockerClient struct {
+	Socket string
+
+	client *http.Client
+}
+
+// DockerContainer represents a container returned by the Engine API.
+type DockerContainer struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Image  string            `json:"Image"`
+	State  string            `json:"State"`
+	Labels map[string]string `json:"Labels"`
+
+	NetworkSettings struct {
+		Networks map[string]DockerNetwork `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// DockerNetwork represents the network settings of a container.
+type DockerNetwork struct {
+	IPAddress         string `json:"IPAddress"`
+	GlobalIPv6Address string `json:"GlobalIPv6Address"`
+}
+
+// DockerExec represents a command to execute in a container.
+type DockerExec struct {
+	Cmd        []string
+	Env        []string
+	WorkingDir string
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Timeout is the amount of time to wait for the command to exit
+	// once its output has closed.
+	Timeout time.Duration
+}
+
+// DockerError is an error returned by the Engine API.
+type DockerError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e DockerError) Error() string {
+	return e.Message
+}
+
+// NewDockerClient will return a client of the Engine API listening on
+// socket. If socket is empty, DOCKER_HOST is used if it is a unix
+// socket, otherwise the default Docker socket is used.
+func NewDockerClient(socket string) *DockerClient {
+	if socket == "" {
+		socket = DockerDefaultSocket
+		if v := os.Getenv("DOCKER_HOST"); strings.HasPrefix(v, "unix://") {
+			socket = strings.TrimPrefix(v, "unix://")
+		}
+	}
+
+	dial := func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", socket)
+	}
+
+	return &DockerClient{
+		Socket: socket,
+		client: &http.Client{
+			Transport: &http.Transport{DialContext: dial},
+		},
+	}
+}
+
+// Name returns the name of a container.
+func (r DockerContainer) Name() string {
+	if len(r.Names) == 0 {
+		return r.ID
+	}
+
+	return strings.TrimPrefix(r.Names[0], "/")
+}
+
+// ListContainers returns the running containers which have all of
+// the given labels.
+func (r *DockerClient) ListContainers(labels map[string]string) ([]DockerContainer, error) {
+	var containers []DockerContainer
+
+	var filter []string
+	for k, v := range labels {
+		filter = append(filter, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(filter)
+
+	query := url.Values{}
+	if len(filter) > 0 {
+		b, err := json.Marshal(map[string][]string{"label": filter})
+		if err != nil {
+			return nil, err
+		}
+
+		query.Set("filters", string(b))
+	}
+
+	if err := r.doJSON("GET", "/containers/json", query, nil, &containers); err != nil {
+		return nil, err
+	}
+
+	return containers, nil
+}
+
+// ContainerRunning returns if a container is running.
+func (r *DockerClient) ContainerRunning(container string) (bool, error) {
+	var v struct {
+		State struct {
+			Running bool `json:"Running"`
+		} `json:"State"`
+	}
+
+	path := fmt.Sprintf("/containers/%s/json", url.PathEscape(container))
+	if err := r.doJSON("GET", path, nil, nil, &v); err != nil {
+		return false, err
+	}
+
+	return v.State.Running, nil
+}
+
+// Exec will execute a command in a container and return its exit code.
+func (r *DockerClient) Exec(container string, e DockerExec) (int, error) {
+	var created struct {
+		ID string `json:"Id"`
+	}
+
+	config := map[string]interface{}{
+		"AttachStdin":  e.Stdin != nil,
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Cmd":          e.Cmd,
+		"Env":          e.Env,
+		"WorkingDir":   e.WorkingDir,
+	}
+
+	path := fmt.Sprintf("/containers/%s/exec", url.PathEscape(container))
+	if err := r.doJSON("POST", path, nil, config, &created); err != nil {
+		return 0, err
+	}
+
+	if err := r.startExec(created.ID, e); err != nil {
+		return 0, err
+	}
+
+	// The output of an exec can close before the exec has exited and
+	// its exit code is known, so the exec is inspected until it is no
+	// longer running.
+	var inspect struct {
+		ExitCode int  `json:"ExitCode"`
+		Running  bool `json:"Running"`
+	}
+
+	path = fmt.Sprintf("/exec/%s/json", created.ID)
+	deadline := time.Now().Add(e.Timeout)
+	for {
+		if err := r.doJSON("GET", path, nil, nil, &inspect); err != nil {
+			return 0, err
+		}
+
+		if !inspect.Running {
+			return inspect.ExitCode, nil
+		}
+
+		if time.Now().After(deadline) {
+			return 0, fmt.Errorf("exec %s did not exit after %s", created.ID, e.Timeout)
+		}
+
+		time.Sleep(dockerExecPollInterval)
+	}
+}
+
+// startExec will start an exec and copy its input and output. The
+// connection is upgraded to a raw stream, so it is made by hand
+// instead of through the HTTP client.
+func (r *DockerClient) startExec(id string, e DockerExec) error {
+	conn, err := net.Dial("unix", r.Socket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	body := []byte(`{"Detach":false,"Tty":false}`)
+	req, err := http.NewRequest("POST", r.url(fmt.Sprintf("/exec/%s/start", id), nil), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return err
+	}
+
+	var stream io.Reader
+	switch resp.StatusCode {
+	case http.StatusSwitchingProtocols:
+		stream = br
+	case http.StatusOK:
+		stream = resp.Body
+	default:
+		defer resp.Body.Close()
+		return dockerError(resp)
+	}
+
+	if e.Stdin != nil {
+		go func() {
+			io.Copy(conn, e.Stdin)
+			if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+				cw.CloseWrite()
+			}
+		}()
+	}
+
+	return demuxDockerStream(stream, e.Stdout, e.Stderr)
+}
+
+// PutArchive will extract a tar archive to a directory of a container.
+// The owner of the files in the archive is not kept by every server,
+// so callers which need a specific owner must set it afterwards.
+func (r *DockerClient) PutArchive(container, dir string, archive io.Reader) error {
+	query := url.Values{}
+	query.Set("path", dir)
+
+	path := fmt.Sprintf("/containers/%s/archive", url.PathEscape(container))
+	resp, err := r.do("PUT", path, query, archive, "application/x-tar")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// GetArchive returns a tar archive of a path of a container.
+func (r *DockerClient) GetArchive(container, p string) (io.ReadCloser, error) {
+	query := url.Values{}
+	query.Set("path", p)
+
+	path := fmt.Sprintf("/containers/%s/archive", url.PathEscape(container))
+	resp, err := r.do("GET", path, query, nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// url returns the URL of an API path. The host is ignored since
+// requests are made over the socket.
+func (r *DockerClient) url(path string, query url.Values) string {
+	u := url.URL{
+		Scheme:   "http",
+		Host:     "docker",
+		Path:     "/" + DockerAPIVersion + path,
+		RawQuery: query.Encode(),
+	}
+
+	return u.String()
+}
+
+// do will make a request to the API. An error is returned if the
+// API responded with an error.
+func (r *DockerClient) do(method, path string, query url.Values, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequest(method, r.url(path, query), body)
+	if err != nil {
+		return nil, err
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, dockerError(resp)
+	}
+
+	return resp, nil
+}
+
+// doJSON will make a request with a JSON body and decode the JSON
+// response into out.
+func (r *DockerClient) doJSON(method, path string, query url.Values, in, out interface{}) error {
+	var body io.Reader
+	var contentType string
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+
+		body = bytes.NewReader(b)
+		contentType = "application/json"
+	}
+
+	resp, err := r.do(method, path, query, body, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// dockerError returns the error of an API response.
+func dockerError(resp *http.Response) error {
+	var v struct {
+		Message string `json:"message"`
+	}
+
+	b, _ := ioutil.ReadAll(resp.Body)
+	if err := json.Unmarshal(b, &v); err != nil || v.Message == "" {
+		v.Message = strings.TrimSpace(string(b))
+	}
+
+	if v.Message == "" {
+		v.Message = resp.Status
+	}
+
+	return DockerError{
+		StatusCode: resp.StatusCode,
+		Message:    v.Message,
+	}
+}
+
+// demuxDockerStream will split the stream of an exec into stdout and
+// stderr. Each frame of the stream has an 8 byte header with the
+// stream type and the size of the frame.
+func demuxDockerStream(r io.Reader, stdout, stderr io.Writer) error {
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		}
+
+		w := stdout
+		if header[0] == 2 {
+			w = stderr
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, r, size); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,464 @@
+package targets
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// ansibleUngroupedGroup is the group of hosts which are not in
+	// any other group of an INI inventory.
+	ansibleUngroupedGroup = "ungrouped"
+)
+
+// ansibleHostRangeRe matches the first range of a host pattern,
+// such as web[01:20].example.com or db-[a:c].
+var ansibleHostRangeRe = regexp.MustCompile(`^(.*?)\[([a-z0-9]+):([a-z0-9]+)(?::([0-9]+))?\](.*)$`)
+
+// AnsibleInventory represents an ansible_inventory target driver.
+type AnsibleInventory struct {
+	File  string `mapstructure:"file"`
+	Group string `mapstructure:"group"`
+}
+
+// ansibleYAMLGroup represents a group of a YAML inventory.
+type ansibleYAMLGroup struct {
+	Hosts    map[string]map[string]interface{} `yaml:"hosts"`
+	Children map[string]*ansibleYAMLGroup      `yaml:"children"`
+	Vars     map[string]interface{}            `yaml:"vars"`
+}
+
+// NewAnsibleInventory will return an AnsibleInventory.
+func NewAnsibleInventory(options map[string]interface{}) (*AnsibleInventory, error) {
+	var ai AnsibleInventory
+
+	err := mapstructure.Decode(options, &ai)
+	if err != nil {
+		return nil, err
+	}
+
+	if ai.File == "" {
+		return nil, fmt.Errorf("file is a required option for ansible_inventory")
+	}
+
+	if v, ok := options["_dir"]; ok {
+		if dir, ok := v.(string); ok && !path.IsAbs(ai.File) {
+			ai.File = path.Join(dir, ai.File)
+		}
+	}
+
+	if _, err := os.Stat(ai.File); os.IsNotExist(err) {
+		return nil, fmt.Errorf("file %s does not exist", ai.File)
+	}
+
+	return &ai, nil
+}
+
+// Discover implements the Target interface for an ansible_inventory driver.
+// It returns the hosts of a group of an INI or YAML Ansible inventory,
+// or every host if no group was specified.
+func (r AnsibleInventory) Discover() ([]Host, error) {
+	b, err := ioutil.ReadFile(r.File)
+	if err != nil {
+		return nil, err
+	}
+
+	inv := &inventory{
+		Hosts:  make(map[string]*inventoryHost),
+		Groups: make(map[string]*inventoryGroup),
+	}
+
+	switch path.Ext(r.File) {
+	case ".yaml", ".yml":
+		err = parseAnsibleYAML(inv, b)
+	default:
+		err = parseAnsibleINI(inv, b)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse inventory %s: %s", r.File, err)
+	}
+
+	hosts, err := inv.targetHosts(r.Group)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read inventory %s: %s", r.File, err)
+	}
+
+	// The address and port of a host can be set by its variables or
+	// the variables of any of its groups.
+	for i, host := range hosts {
+		if v, ok := host.Vars["ansible_host"]; ok {
+			hosts[i].Address = bracketIPv6(fmt.Sprintf("%v", v))
+		}
+
+		if v, ok := host.Vars["ansible_port"]; ok {
+			port, err := strconv.Atoi(fmt.Sprintf("%v", v))
+			if err != nil {
+				return nil, fmt.Errorf("invalid ansible_port of %s: %v", host.Name, v)
+			}
+			hosts[i].Port = port
+		}
+	}
+
+	return hosts, nil
+}
+
+// parseAnsibleINI will parse an INI inventory. Sections are groups,
+// and a section can also list the children or the variables of a
+// group as [group:children] or [group:vars].
+func parseAnsibleINI(inv *inventory, b []byte) error {
+	group := ansibleUngroupedGroup
+	kind := "hosts"
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			group = strings.TrimSpace(line[1 : len(line)-1])
+			kind = "hosts"
+
+			if i := strings.LastIndex(group, ":"); i > -1 {
+				kind = group[i+1:]
+				group = group[:i]
+			}
+
+			switch kind {
+			case "hosts", "children", "vars":
+			default:
+				return fmt.Errorf("line %d: invalid section type: %s", n, kind)
+			}
+
+			inv.group(group)
+			continue
+		}
+
+		switch kind {
+		case "children":
+			inv.addChild(group, line)
+
+		case "vars":
+			kv := strings.SplitN(line, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("line %d: variables must be key=value", n)
+			}
+
+			key := strings.TrimSpace(kv[0])
+			inv.group(group).Vars[key] = unquoteAnsibleValue(strings.TrimSpace(kv[1]))
+
+		default:
+			fields, err := splitAnsibleLine(line)
+			if err != nil {
+				return fmt.Errorf("line %d: %s", n, err)
+			}
+
+			if len(fields) == 0 {
+				continue
+			}
+
+			vars := make(map[string]interface{})
+			for _, field := range fields[1:] {
+				kv := strings.SplitN(field, "=", 2)
+				if len(kv) != 2 {
+					return fmt.Errorf("line %d: variables must be key=value: %s", n, field)
+				}
+
+				vars[kv[0]] = parseAnsibleValue(kv[1])
+			}
+
+			if err := addAnsibleHosts(inv, group, fields[0], vars); err != nil {
+				return fmt.Errorf("line %d: %s", n, err)
+			}
+		}
+	}
+
+	return scanner.Err()
+}
+
+// parseAnsibleYAML will parse a YAML inventory. The top level keys
+// are groups, usually only "all".
+func parseAnsibleYAML(inv *inventory, b []byte) error {
+	var groups map[string]*ansibleYAMLGroup
+
+	if err := yaml.Unmarshal(b, &groups); err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := addAnsibleYAMLGroup(inv, name, groups[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addAnsibleYAMLGroup will add a group of a YAML inventory and its
+// children to an inventory.
+func addAnsibleYAMLGroup(inv *inventory, name string, yg *ansibleYAMLGroup) error {
+	group := inv.group(name)
+	if yg == nil {
+		return nil
+	}
+
+	for k, v := range yg.Vars {
+		group.Vars[k] = v
+	}
+
+	// YAML maps are unordered, so hosts and children are added in
+	// order of their names.
+	var patterns []string
+	for pattern := range yg.Hosts {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	for _, pattern := range patterns {
+		if err := addAnsibleHosts(inv, name, pattern, yg.Hosts[pattern]); err != nil {
+			return err
+		}
+	}
+
+	var children []string
+	for child := range yg.Children {
+		children = append(children, child)
+	}
+	sort.Strings(children)
+
+	for _, child := range children {
+		inv.addChild(name, child)
+		if err := addAnsibleYAMLGroup(inv, child, yg.Children[child]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addAnsibleHosts will add the hosts of a host pattern to a group of
+// an inventory. The pattern can include ranges and a port.
+func addAnsibleHosts(inv *inventory, name, pattern string, vars map[string]interface{}) error {
+	group := inv.group(name)
+
+	// A pattern can end with a port, as in host:2222 or
+	// web[01:20]:2222. The port is after the last range, and a bare
+	// IPv6 address, which has more than one colon, has no port.
+	suffix := pattern
+	if i := strings.LastIndex(pattern, "]"); i > -1 {
+		suffix = pattern[i+1:]
+	}
+
+	if i := strings.LastIndex(suffix, ":"); i > -1 && strings.Count(suffix, ":") == 1 {
+		if port, err := strconv.Atoi(suffix[i+1:]); err == nil {
+			if vars == nil {
+				vars = make(map[string]interface{})
+			}
+
+			if _, ok := vars["ansible_port"]; !ok {
+				vars["ansible_port"] = port
+			}
+
+			pattern = pattern[:len(pattern)-len(suffix)+i]
+		}
+	}
+
+	names, err := expandAnsibleHostPattern(pattern)
+	if err != nil {
+		return err
+	}
+
+	for _, hostName := range names {
+		host, ok := inv.Hosts[hostName]
+		if !ok {
+			host = &inventoryHost{}
+			inv.Hosts[hostName] = host
+		}
+
+		if host.Vars == nil {
+			host.Vars = make(map[string]interface{})
+		}
+
+		for k, v := range vars {
+			host.Vars[k] = v
+		}
+
+		var exists bool
+		for _, v := range group.Hosts {
+			if v == hostName {
+				exists = true
+				break
+			}
+		}
+
+		if !exists {
+			group.Hosts = append(group.Hosts, hostName)
+		}
+	}
+
+	return nil
+}
+
+// expandAnsibleHostPattern returns the hosts of a host pattern.
+// Numeric ranges which start with a zero are padded, so web[01:03]
+// is web01, web02, and web03. A range can also have a step, as in
+// web[1:9:2], or be alphabetic, as in db-[a:c].
+func expandAnsibleHostPattern(pattern string) ([]string, error) {
+	m := ansibleHostRangeRe.FindStringSubmatch(pattern)
+	if m == nil {
+		return []string{pattern}, nil
+	}
+
+	prefix, start, end, stepStr, suffix := m[1], m[2], m[3], m[4], m[5]
+
+	step := 1
+	if stepStr != "" {
+		v, err := strconv.Atoi(stepStr)
+		if err != nil || v < 1 {
+			return nil, fmt.Errorf("invalid step in host range: %s", pattern)
+		}
+		step = v
+	}
+
+	var items []string
+	startNum, startErr := strconv.Atoi(start)
+	endNum, endErr := strconv.Atoi(end)
+
+	switch {
+	case startErr == nil && endErr == nil:
+		format := "%d"
+		if len(start) > 1 && start[0] == '0' {
+			if len(start) != len(end) {
+				return nil, fmt.Errorf("host range must have equal length start and end: %s", pattern)
+			}
+			format = fmt.Sprintf("%%0%dd", len(start))
+		}
+
+		for i := startNum; i <= endNum; i += step {
+			items = append(items, fmt.Sprintf(format, i))
+		}
+
+	case len(start) == 1 && len(end) == 1 && startErr != nil && endErr != nil:
+		for c := int(start[0]); c <= int(end[0]); c += step {
+			items = append(items, string(rune(c)))
+		}
+
+	default:
+		return nil, fmt.Errorf("invalid host range: %s", pattern)
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("host range is empty: %s", pattern)
+	}
+
+	// The suffix can have more ranges.
+	suffixes, err := expandAnsibleHostPattern(suffix)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, item := range items {
+		for _, s := range suffixes {
+			names = append(names, prefix+item+s)
+		}
+	}
+
+	return names, nil
+}
+
+// splitAnsibleLine will split a host line of an INI inventory into
+// fields. Quoted values can contain spaces, and an unquoted # starts
+// a comment.
+func splitAnsibleLine(line string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	var quote rune
+	var inField bool
+
+	for _, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+			field.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote = c
+			inField = true
+			field.WriteRune(c)
+		case c == ' ' || c == '\t':
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		case c == '#' && !inField:
+			return fields, nil
+		default:
+			inField = true
+			field.WriteRune(c)
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+
+	if inField {
+		fields = append(fields, field.String())
+	}
+
+	return fields, nil
+}
+
+// parseAnsibleValue parses a variable of a host line. As in Ansible,
+// numbers and booleans are converted and quotes are removed. The
+// variables of a :vars section are always strings.
+func parseAnsibleValue(v string) interface{} {
+	if i, err := strconv.Atoi(v); err == nil {
+		return i
+	}
+
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		return f
+	}
+
+	switch v {
+	case "True", "true":
+		return true
+	case "False", "false":
+		return false
+	}
+
+	return unquoteAnsibleValue(v)
+}
+
+// unquoteAnsibleValue removes the quotes around a value.
+func unquoteAnsibleValue(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+
+	return v
+}
@@ -0,0 +1,102 @@
+package targets
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jtopjian/yak/lib/shared"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// DockerContainers represents a docker_containers target driver.
+type DockerContainers struct {
+	Labels  map[string]string `mapstructure:"labels"`
+	Network string            `mapstructure:"network"`
+	Socket  string            `mapstructure:"socket"`
+	UseIPv6 bool              `mapstructure:"use_ipv6"`
+
+	client *shared.DockerClient
+}
+
+// NewDockerContainers will return a DockerContainers.
+func NewDockerContainers(options map[string]interface{}) (*DockerContainers, error) {
+	var dc DockerContainers
+
+	err := mapstructure.Decode(options, &dc)
+	if err != nil {
+		return nil, err
+	}
+
+	dc.client = shared.NewDockerClient(dc.Socket)
+
+	return &dc, nil
+}
+
+// Discover implements the Target interface for a docker_containers driver.
+// It returns the running containers of a Docker or Podman server.
+func (r DockerContainers) Discover() ([]Host, error) {
+	var hosts []Host
+
+	// The containers are filtered by labels by the server.
+	containers, err := r.client.ListContainers(r.Labels)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get containers: %s", err)
+	}
+
+	for _, container := range containers {
+		host := Host{
+			Name: container.Name(),
+			Vars: dockerContainerVars(container),
+		}
+
+		network, ok := r.network(container)
+		if ok {
+			host.Address = network.IPAddress
+			if r.UseIPv6 && network.GlobalIPv6Address != "" {
+				host.Address = fmt.Sprintf("[%s]", network.GlobalIPv6Address)
+			}
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	return hosts, nil
+}
+
+// network returns the network of a container to connect via. If
+// no network was specified, the first network by name is used.
+func (r DockerContainers) network(container shared.DockerContainer) (shared.DockerNetwork, bool) {
+	networks := container.NetworkSettings.Networks
+
+	if r.Network != "" {
+		network, ok := networks[r.Network]
+		return network, ok
+	}
+
+	var names []string
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		return shared.DockerNetwork{}, false
+	}
+
+	return networks[names[0]], true
+}
+
+// dockerContainerVars returns the variables of a container.
+func dockerContainerVars(container shared.DockerContainer) map[string]interface{} {
+	labels := make(map[string]interface{})
+	for k, v := range container.Labels {
+		labels[k] = v
+	}
+
+	return map[string]interface{}{
+		"id":     container.ID,
+		"image":  container.Image,
+		"labels": labels,
+	}
+}
@@ -0,0 +1,148 @@
+package targets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	ExecDefaultTimeout = 60
+
+	// execWaitDelay is how long to wait for the output of a program
+	// to be closed once it has exited or timed out. A process which
+	// the program started in the background can hold its output open.
+	execWaitDelay = 1 * time.Second
+)
+
+// Exec represents an exec target driver. It runs a local program,
+// such as a dynamic inventory script, which prints the hosts.
+type Exec struct {
+	Args    []string          `mapstructure:"args"`
+	Command string            `mapstructure:"command"`
+	Env     map[string]string `mapstructure:"env"`
+	Timeout int               `mapstructure:"timeout"`
+
+	dir string
+}
+
+// execHost represents a host printed by the program of an exec target.
+type execHost struct {
+	Name    string                 `json:"name"`
+	Address string                 `json:"address"`
+	Vars    map[string]interface{} `json:"vars"`
+}
+
+// NewExec will return an Exec.
+func NewExec(options map[string]interface{}) (*Exec, error) {
+	var e Exec
+
+	err := mapstructure.Decode(options, &e)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Command == "" {
+		return nil, fmt.Errorf("command is a required option for exec")
+	}
+
+	if e.Timeout == 0 {
+		e.Timeout = ExecDefaultTimeout
+	}
+
+	// The program is run in the directory of the yakfile. A relative
+	// path to the program is relative to that directory, too, while
+	// a plain name is looked up in PATH.
+	if v, ok := options["_dir"]; ok {
+		if dir, ok := v.(string); ok {
+			e.dir = dir
+			if strings.Contains(e.Command, "/") && !filepath.IsAbs(e.Command) {
+				command, err := filepath.Abs(filepath.Join(dir, e.Command))
+				if err != nil {
+					return nil, err
+				}
+				e.Command = command
+			}
+		}
+	}
+
+	return &e, nil
+}
+
+// Discover implements the Target interface for an exec driver.
+// It returns the hosts which the program printed as JSON.
+func (r Exec) Discover() ([]Host, error) {
+	var stdout, stderr bytes.Buffer
+	var hosts []Host
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(r.Timeout)*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, r.Command, r.Args...)
+	cmd.Dir = r.dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = execWaitDelay
+
+	if len(r.Env) > 0 {
+		var keys []string
+		for k := range r.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		cmd.Env = os.Environ()
+		for _, k := range keys {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, r.Env[k]))
+		}
+	}
+
+	// The output of a program which exited successfully is complete,
+	// even if a background process still holds it open.
+	if err := cmd.Run(); err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("%s timed out after %d seconds", r.Command, r.Timeout)
+		}
+
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %s: %s", r.Command, err, msg)
+		}
+
+		return nil, fmt.Errorf("%s failed: %s", r.Command, err)
+	}
+
+	var execHosts []execHost
+	if err := json.Unmarshal(stdout.Bytes(), &execHosts); err != nil {
+		return nil, fmt.Errorf("unable to parse hosts of %s: %s", r.Command, err)
+	}
+
+	for i, eh := range execHosts {
+		if eh.Name == "" {
+			return nil, fmt.Errorf("host %d of %s has no name", i, r.Command)
+		}
+
+		host := Host{
+			Name:    eh.Name,
+			Address: eh.Address,
+			Vars:    eh.Vars,
+		}
+
+		if host.Address == "" {
+			host.Address = host.Name
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	return hosts, nil
+}
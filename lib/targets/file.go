@@ -0,0 +1,79 @@
+package targets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+
+	"gopkg.in/yaml.v2"
+)
+
+// File represents a file target driver. It reads hosts and groups
+// of hosts from a YAML or JSON inventory file.
+type File struct {
+	File  string `mapstructure:"file"`
+	Group string `mapstructure:"group"`
+}
+
+// NewFile will return a File.
+func NewFile(options map[string]interface{}) (*File, error) {
+	var file File
+
+	err := mapstructure.Decode(options, &file)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.File == "" {
+		return nil, fmt.Errorf("file is a required option for file")
+	}
+
+	if v, ok := options["_dir"]; ok {
+		if dir, ok := v.(string); ok && !path.IsAbs(file.File) {
+			file.File = path.Join(dir, file.File)
+		}
+	}
+
+	if _, err := os.Stat(file.File); os.IsNotExist(err) {
+		return nil, fmt.Errorf("file %s does not exist", file.File)
+	}
+
+	return &file, nil
+}
+
+// Discover implements the Target interface for a file driver.
+// It returns the hosts of a group of an inventory file, or every
+// host if no group was specified.
+func (r File) Discover() ([]Host, error) {
+	var inv inventory
+
+	b, err := ioutil.ReadFile(r.File)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(r.File, ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&inv)
+	} else {
+		err = yaml.UnmarshalStrict(b, &inv)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse inventory %s: %s", r.File, err)
+	}
+
+	hosts, err := inv.targetHosts(r.Group)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read inventory %s: %s", r.File, err)
+	}
+
+	return hosts, nil
+}
@@ -0,0 +1,308 @@
+package targets
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/jtopjian/yak/lib/utils"
+)
+
+// inventoryAllGroup is the group which every host of an inventory
+// belongs to.
+const inventoryAllGroup = "all"
+
+// inventory represents hosts and groups of hosts which are read by
+// an inventory target driver, such as file.
+type inventory struct {
+	Hosts  map[string]*inventoryHost  `json:"hosts" yaml:"hosts"`
+	Groups map[string]*inventoryGroup `json:"groups" yaml:"groups"`
+}
+
+// inventoryHost represents a host of an inventory.
+type inventoryHost struct {
+	Address string                 `json:"address" yaml:"address"`
+	Port    int                    `json:"port" yaml:"port"`
+	Vars    map[string]interface{} `json:"vars" yaml:"vars"`
+}
+
+// inventoryGroup represents a group of an inventory. The hosts of
+// its children are also hosts of the group.
+type inventoryGroup struct {
+	Hosts    []string               `json:"hosts" yaml:"hosts"`
+	Children []string               `json:"children" yaml:"children"`
+	Vars     map[string]interface{} `json:"vars" yaml:"vars"`
+}
+
+// validate ensures the groups of an inventory only have children
+// which exist and that a group is not its own descendant. Hosts and
+// groups which were defined without any options are initialized.
+func (r *inventory) validate() error {
+	for name, host := range r.Hosts {
+		if host == nil {
+			r.Hosts[name] = &inventoryHost{}
+		}
+	}
+
+	for name, group := range r.Groups {
+		if group == nil {
+			r.Groups[name] = &inventoryGroup{}
+		}
+	}
+
+	for _, name := range sortedGroupNames(r.Groups) {
+		if _, err := r.groupHosts(name, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// groupHosts returns the names of the hosts of a group and its
+// children, in order and without duplicates. The "all" group has
+// every host, whether or not it is defined.
+func (r *inventory) groupHosts(name string, parents []string) ([]string, error) {
+	for _, parent := range parents {
+		if parent == name {
+			return nil, fmt.Errorf("group %s is a child of itself", name)
+		}
+	}
+
+	group, ok := r.Groups[name]
+	if !ok && name != inventoryAllGroup {
+		if len(parents) > 0 {
+			return nil, fmt.Errorf("group %s has an unknown child: %s", parents[len(parents)-1], name)
+		}
+
+		return nil, fmt.Errorf("unknown group: %s", name)
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	add := func(hosts []string) {
+		for _, host := range hosts {
+			if !seen[host] {
+				seen[host] = true
+				names = append(names, host)
+			}
+		}
+	}
+
+	if ok {
+		add(group.Hosts)
+
+		for _, child := range group.Children {
+			hosts, err := r.groupHosts(child, append(parents, name))
+			if err != nil {
+				return nil, err
+			}
+
+			add(hosts)
+		}
+	}
+
+	if name == inventoryAllGroup {
+		return r.allHosts(), nil
+	}
+
+	return names, nil
+}
+
+// allHosts returns the names of every host of an inventory in order.
+func (r *inventory) allHosts() []string {
+	seen := make(map[string]bool)
+	for name := range r.Hosts {
+		seen[name] = true
+	}
+
+	for _, group := range r.Groups {
+		for _, name := range group.Hosts {
+			seen[name] = true
+		}
+	}
+
+	var names []string
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// targetHosts returns the hosts of a group. If group is empty, every
+// host is returned.
+func (r *inventory) targetHosts(group string) ([]Host, error) {
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	if group == "" {
+		group = inventoryAllGroup
+	}
+
+	names, err := r.groupHosts(group, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The variables of groups are applied in order of their depth,
+	// so the variables of a child override those of its parents.
+	depths := r.groupDepths()
+	groups := sortedGroupNames(r.Groups)
+	sort.SliceStable(groups, func(i, j int) bool {
+		return depths[groups[i]] < depths[groups[j]]
+	})
+
+	members := make(map[string]map[string]bool)
+	for _, group := range groups {
+		groupHosts, err := r.groupHosts(group, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		members[group] = make(map[string]bool)
+		for _, host := range groupHosts {
+			members[group][host] = true
+		}
+	}
+
+	var hosts []Host
+	for _, name := range names {
+		host := Host{
+			Name:    name,
+			Address: name,
+		}
+
+		if ih, ok := r.Hosts[name]; ok {
+			if ih.Address != "" {
+				host.Address = ih.Address
+			}
+			host.Port = ih.Port
+		}
+
+		host.Address = bracketIPv6(host.Address)
+
+		if vars := r.hostVars(name, groups, members); len(vars) > 0 {
+			host.Vars = vars
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	return hosts, nil
+}
+
+// hostVars returns the variables of a host. The variables of the
+// groups of the host are applied first, in the order of groups, then
+// the variables of the host itself. members are the hosts of each
+// group.
+func (r *inventory) hostVars(name string, groups []string, members map[string]map[string]bool) map[string]interface{} {
+	vars := make(map[string]interface{})
+
+	for _, group := range groups {
+		if !members[group][name] {
+			continue
+		}
+
+		for k, v := range r.Groups[group].Vars {
+			vars[k] = utils.NormalizeValue(v)
+		}
+	}
+
+	if ih, ok := r.Hosts[name]; ok {
+		for k, v := range ih.Vars {
+			vars[k] = utils.NormalizeValue(v)
+		}
+	}
+
+	return vars
+}
+
+// groupDepths returns how deep each group is nested. Groups without
+// parents have a depth of 0, and the "all" group comes before them.
+func (r *inventory) groupDepths() map[string]int {
+	parents := make(map[string][]string)
+	for name, group := range r.Groups {
+		for _, child := range group.Children {
+			parents[child] = append(parents[child], name)
+		}
+	}
+
+	depths := map[string]int{inventoryAllGroup: -1}
+	var depth func(name string) int
+	depth = func(name string) int {
+		if d, ok := depths[name]; ok {
+			return d
+		}
+
+		var d int
+		for _, parent := range parents[name] {
+			if pd := depth(parent) + 1; pd > d {
+				d = pd
+			}
+		}
+
+		depths[name] = d
+
+		return d
+	}
+
+	for name := range r.Groups {
+		depth(name)
+	}
+
+	return depths
+}
+
+// group returns a group of an inventory, adding it if it doesn't
+// exist yet.
+func (r *inventory) group(name string) *inventoryGroup {
+	group, ok := r.Groups[name]
+	if !ok {
+		group = &inventoryGroup{}
+		r.Groups[name] = group
+	}
+
+	if group.Vars == nil {
+		group.Vars = make(map[string]interface{})
+	}
+
+	return group
+}
+
+// addChild will add a child to a group of an inventory.
+func (r *inventory) addChild(name, child string) {
+	group := r.group(name)
+	r.group(child)
+
+	for _, v := range group.Children {
+		if v == child {
+			return
+		}
+	}
+
+	group.Children = append(group.Children, child)
+}
+
+// sortedGroupNames returns the names of groups in order.
+func sortedGroupNames(groups map[string]*inventoryGroup) []string {
+	var names []string
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// bracketIPv6 will wrap an IPv6 address in brackets so a port can be
+// appended to it.
+func bracketIPv6(address string) string {
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		return fmt.Sprintf("[%s]", address)
+	}
+
+	return address
+}
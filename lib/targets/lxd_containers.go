@@ -2,6 +2,7 @@ package targets
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/jtopjian/yak/lib/config"
 	"github.com/jtopjian/yak/lib/shared"
@@ -107,6 +108,7 @@ func (r LXDContainers) Discover() ([]Host, error) {
 	for _, container := range filteredContainers {
 		host := Host{
 			Name: container.Name,
+			Vars: lxdContainerVars(container),
 		}
 
 		cstate, _, err := r.client.GetContainerState(container.Name)
@@ -135,3 +137,30 @@ func (r LXDContainers) Discover() ([]Host, error) {
 
 	return hosts, nil
 }
+
+// lxdContainerVars returns the variables of a container.
+// The variables are built from the container's expanded config,
+// which includes the config of the container's profiles. Any
+// user.* config key is also available without its prefix.
+func lxdContainerVars(container lxd_api.Container) map[string]interface{} {
+	config := make(map[string]interface{})
+	vars := make(map[string]interface{})
+
+	for k, v := range container.ExpandedConfig {
+		config[k] = v
+
+		if strings.HasPrefix(k, "user.") {
+			vars[strings.TrimPrefix(k, "user.")] = v
+		}
+	}
+
+	profiles := make([]interface{}, len(container.Profiles))
+	for i, v := range container.Profiles {
+		profiles[i] = v
+	}
+
+	vars["config"] = config
+	vars["profiles"] = profiles
+
+	return vars
+}
@@ -211,7 +211,9 @@ func (r OpenStackInstances) Discover() ([]Host, error) {
 			}
 		}
 
-		var host Host
+		host := Host{
+			Vars: openStackInstanceVars(s),
+		}
 
 		// nics either contains all nics of the server
 		// or just the access network.
@@ -238,3 +240,34 @@ func (r OpenStackInstances) Discover() ([]Host, error) {
 
 	return hosts, nil
 }
+
+// openStackInstanceVars returns the variables of an instance.
+// The variables are built from the instance's metadata, flavor,
+// and image.
+func openStackInstanceVars(s servers.Server) map[string]interface{} {
+	metadata := make(map[string]interface{})
+	for k, v := range s.Metadata {
+		metadata[k] = v
+	}
+
+	vars := map[string]interface{}{
+		"id":       s.ID,
+		"metadata": metadata,
+	}
+
+	if v, ok := s.Flavor["id"]; ok {
+		vars["flavor_id"] = v
+	}
+
+	// Newer compute microversions embed the flavor name
+	// instead of the flavor ID.
+	if v, ok := s.Flavor["original_name"]; ok {
+		vars["flavor_name"] = v
+	}
+
+	if v, ok := s.Image["id"]; ok {
+		vars["image_id"] = v
+	}
+
+	return vars
+}
@@ -14,6 +14,14 @@ type Target interface {
 type Host struct {
 	Name    string
 	Address string
+
+	// Port is the port to connect to on the host. It is only set
+	// if the target driver knows it.
+	Port int
+
+	// Vars are variables which the target driver knows
+	// about the host.
+	Vars map[string]interface{}
 }
 
 // New will return a target based on a given target driver.
@@ -23,12 +31,22 @@ func New(targetType string, options map[string]interface{}) (Target, error) {
 	}
 
 	switch targetType {
+	case "ansible_inventory":
+		return NewAnsibleInventory(options)
+	case "docker_containers":
+		return NewDockerContainers(options)
+	case "exec":
+		return NewExec(options)
+	case "file":
+		return NewFile(options)
 	case "local":
 		return NewLocal(options)
 	case "lxd_containers":
 		return NewLXDContainers(options)
 	case "openstack_instances":
 		return NewOpenStackInstances(options)
+	case "terraform_state":
+		return NewTerraformState(options)
 	case "textfile":
 		return NewTextFile(options)
 	default:
@@ -0,0 +1,211 @@
+package targets
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	TerraformStateDefaultFile             = "terraform.tfstate"
+	TerraformStateDefaultNameAttribute    = "name"
+	TerraformStateDefaultAddressAttribute = "access_ip_v4"
+)
+
+// TerraformState represents a terraform_state target driver.
+type TerraformState struct {
+	AddressAttribute string `mapstructure:"address_attribute"`
+	File             string `mapstructure:"file"`
+	NameAttribute    string `mapstructure:"name_attribute"`
+	ResourceName     string `mapstructure:"resource_name"`
+	ResourceType     string `mapstructure:"resource_type"`
+}
+
+// terraformState represents the parts of a version 4 state file
+// which are used to discover hosts.
+type terraformState struct {
+	Version   int                 `json:"version"`
+	Resources []terraformResource `json:"resources"`
+}
+
+// terraformResource represents a resource of a state file.
+type terraformResource struct {
+	Module    string              `json:"module"`
+	Mode      string              `json:"mode"`
+	Type      string              `json:"type"`
+	Name      string              `json:"name"`
+	Instances []terraformInstance `json:"instances"`
+}
+
+// terraformInstance represents an instance of a resource. Resources
+// with count or for_each have an instance for each index.
+type terraformInstance struct {
+	IndexKey   interface{}            `json:"index_key"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// NewTerraformState will return a TerraformState.
+func NewTerraformState(options map[string]interface{}) (*TerraformState, error) {
+	var tfs TerraformState
+
+	err := mapstructure.Decode(options, &tfs)
+	if err != nil {
+		return nil, err
+	}
+
+	if tfs.File == "" {
+		tfs.File = TerraformStateDefaultFile
+	}
+
+	if tfs.NameAttribute == "" {
+		tfs.NameAttribute = TerraformStateDefaultNameAttribute
+	}
+
+	if tfs.AddressAttribute == "" {
+		tfs.AddressAttribute = TerraformStateDefaultAddressAttribute
+	}
+
+	if tfs.ResourceName != "" {
+		if _, err := path.Match(tfs.ResourceName, ""); err != nil {
+			return nil, fmt.Errorf("invalid resource_name %s: %s", tfs.ResourceName, err)
+		}
+	}
+
+	if v, ok := options["_dir"]; ok {
+		if dir, ok := v.(string); ok && !path.IsAbs(tfs.File) {
+			tfs.File = path.Join(dir, tfs.File)
+		}
+	}
+
+	return &tfs, nil
+}
+
+// Discover implements the Target interface for a terraform_state driver.
+// It returns a host for each instance of the matching resources of a
+// Terraform state file.
+func (r TerraformState) Discover() ([]Host, error) {
+	var state terraformState
+	var hosts []Host
+
+	b, err := ioutil.ReadFile(r.File)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read terraform state: %s", err)
+	}
+
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, fmt.Errorf("unable to parse terraform state %s: %s", r.File, err)
+	}
+
+	if state.Version != 4 {
+		return nil, fmt.Errorf("unsupported terraform state version: %d", state.Version)
+	}
+
+	for _, resource := range state.Resources {
+		if !r.match(resource) {
+			continue
+		}
+
+		for _, instance := range resource.Instances {
+			id := resource.id(instance)
+
+			name, err := terraformAttribute(instance.Attributes, r.NameAttribute)
+			if err != nil {
+				return nil, fmt.Errorf("unable to get name of %s: %s", id, err)
+			}
+
+			address, err := terraformAttribute(instance.Attributes, r.AddressAttribute)
+			if err != nil {
+				return nil, fmt.Errorf("unable to get address of %s: %s", id, err)
+			}
+
+			host := Host{
+				Name:    name,
+				Address: bracketIPv6(address),
+				Vars: map[string]interface{}{
+					"resource":   id,
+					"attributes": instance.Attributes,
+				},
+			}
+
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts, nil
+}
+
+// match returns if a resource should be discovered. Data sources
+// are never discovered.
+func (r TerraformState) match(resource terraformResource) bool {
+	if resource.Mode != "managed" {
+		return false
+	}
+
+	if r.ResourceType != "" && r.ResourceType != resource.Type {
+		return false
+	}
+
+	if r.ResourceName != "" {
+		if ok, _ := path.Match(r.ResourceName, resource.Name); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// id returns the address of an instance of a resource as Terraform
+// shows it, such as module.web.aws_instance.web[0].
+func (r terraformResource) id(instance terraformInstance) string {
+	id := fmt.Sprintf("%s.%s", r.Type, r.Name)
+	if r.Module != "" {
+		id = fmt.Sprintf("%s.%s", r.Module, id)
+	}
+
+	switch v := instance.IndexKey.(type) {
+	case float64:
+		id = fmt.Sprintf("%s[%d]", id, int(v))
+	case string:
+		id = fmt.Sprintf("%s[%q]", id, v)
+	}
+
+	return id
+}
+
+// terraformAttribute returns an attribute of an instance. Nested
+// attributes are separated by dots, and list elements are selected
+// by their index, such as network.0.fixed_ip_v4.
+func terraformAttribute(attributes map[string]interface{}, key string) (string, error) {
+	var v interface{} = attributes
+	for _, part := range strings.Split(key, ".") {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			v = value[part]
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(value) {
+				return "", fmt.Errorf("attribute %s not found", key)
+			}
+			v = value[i]
+		default:
+			return "", fmt.Errorf("attribute %s not found", key)
+		}
+	}
+
+	switch value := v.(type) {
+	case string:
+		if value == "" {
+			return "", fmt.Errorf("attribute %s is empty", key)
+		}
+		return value, nil
+	case float64, bool:
+		return fmt.Sprintf("%v", value), nil
+	}
+
+	return "", fmt.Errorf("attribute %s not found", key)
+}
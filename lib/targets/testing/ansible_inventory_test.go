@@ -0,0 +1,206 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/jtopjian/yak/lib/targets"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnsibleInventory_INI(t *testing.T) {
+	target, err := targets.New("ansible_inventory", map[string]interface{}{
+		"_dir":  "fixtures",
+		"file":  "ansible/hosts.ini",
+		"group": "webservers",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := target.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, host := range actual {
+		names = append(names, host.Name)
+	}
+
+	expected := []string{
+		"web01.example.com", "web02.example.com", "web03.example.com", "foo.example.com",
+	}
+	assert.Equal(t, expected, names)
+
+	// Variables of a :vars section are strings.
+	assert.Equal(t, "web01.example.com", actual[0].Address)
+	assert.Equal(t, 22, actual[0].Port)
+	assert.Equal(t, "80", actual[0].Vars["http_port"])
+	assert.Equal(t, "ntp.example.com", actual[0].Vars["ntp_server"])
+	assert.Equal(t, "production", actual[0].Vars["env"])
+	assert.Equal(t, "false", actual[0].Vars["debug"])
+
+	// Variables of a host line are converted.
+	assert.Equal(t, 5309, actual[3].Port)
+	assert.Equal(t, 8080, actual[3].Vars["http_port"])
+	assert.Equal(t, "front page", actual[3].Vars["description"])
+	assert.Equal(t, true, actual[3].Vars["enabled"])
+
+	// The hosts of children are hosts of the group.
+	target, err = targets.New("ansible_inventory", map[string]interface{}{
+		"file":  "fixtures/ansible/hosts.ini",
+		"group": "production",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err = target.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 7, len(actual))
+	assert.Equal(t, "db-a.example.com", actual[4].Name)
+	assert.Equal(t, "10.0.0.5", actual[4].Address)
+	assert.Equal(t, "db-b.example.com", actual[5].Name)
+	assert.Equal(t, "one.example.com", actual[6].Name)
+	assert.Equal(t, "[fd00::1]", actual[6].Address)
+	assert.Equal(t, 2222, actual[6].Port)
+
+	// Hosts without a group are in the "ungrouped" group.
+	target, err = targets.New("ansible_inventory", map[string]interface{}{
+		"file":  "fixtures/ansible/hosts.ini",
+		"group": "ungrouped",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err = target.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedHosts := []targets.Host{
+		targets.Host{
+			Name:    "mail.example.com",
+			Address: "mail.example.com",
+			Port:    22,
+			Vars: map[string]interface{}{
+				"ansible_port": "22",
+			},
+		},
+	}
+
+	assert.Equal(t, expectedHosts, actual)
+
+	// A port after a range is the port of every host of the range,
+	// and a bare IPv6 address has no port.
+	target, err = targets.New("ansible_inventory", map[string]interface{}{
+		"file":  "fixtures/ansible/hosts.ini",
+		"group": "appservers",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err = target.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names = nil
+	for _, host := range actual {
+		names = append(names, host.Name)
+	}
+
+	assert.Equal(t, []string{"app1.example.com", "app2.example.com", "fd00::10"}, names)
+	assert.Equal(t, 2222, actual[0].Port)
+	assert.Equal(t, 2222, actual[1].Port)
+	assert.Equal(t, 22, actual[2].Port)
+}
+
+func TestAnsibleInventory_YAML(t *testing.T) {
+	target, err := targets.New("ansible_inventory", map[string]interface{}{
+		"file":  "fixtures/ansible/hosts.yaml",
+		"group": "webservers",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := target.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, host := range actual {
+		names = append(names, host.Name)
+	}
+
+	expected := []string{
+		"foo.example.com", "web1.example.com", "web3.example.com", "web5.example.com",
+	}
+	assert.Equal(t, expected, names)
+
+	assert.Equal(t, 5309, actual[0].Port)
+	assert.Equal(t, 8080, actual[0].Vars["http_port"])
+	assert.Equal(t, 22, actual[1].Port)
+	assert.Equal(t, 80, actual[1].Vars["http_port"])
+	assert.Equal(t, map[string]interface{}{
+		"servers": []interface{}{"ntp1", "ntp2"},
+	}, actual[1].Vars["ntp"])
+
+	// Without a group, every host is returned.
+	target, err = targets.New("ansible_inventory", map[string]interface{}{
+		"file": "fixtures/ansible/hosts.yaml",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err = target.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 6, len(actual))
+	assert.Equal(t, "db1.example.com", actual[0].Name)
+	assert.Equal(t, "10.0.0.5", actual[0].Address)
+	assert.Equal(t, "production", actual[0].Vars["env"])
+}
+
+func TestAnsibleInventory_Errors(t *testing.T) {
+	target, err := targets.New("ansible_inventory", map[string]interface{}{
+		"file":  "fixtures/ansible/hosts.ini",
+		"group": "missing",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = target.Discover()
+	if assert.Error(t, err) {
+		assert.Equal(t, "unable to read inventory fixtures/ansible/hosts.ini: unknown group: missing", err.Error())
+	}
+
+	target, err = targets.New("ansible_inventory", map[string]interface{}{
+		"file": "fixtures/ansible/bad-range.ini",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = target.Discover()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid host range")
+	}
+
+	_, err = targets.New("ansible_inventory", map[string]interface{}{
+		"file": "fixtures/ansible/missing.ini",
+	})
+	assert.Error(t, err)
+}
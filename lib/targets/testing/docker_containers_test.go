@@ -0,0 +1,111 @@
+package testing
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/jtopjian/yak/lib/targets"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testDockerContainers = `[
+  {
+    "Id": "8dfafdbc3a40",
+    "Names": ["/web1"],
+    "Image": "nginx:latest",
+    "State": "running",
+    "Labels": {"yak": "web", "tier": "frontend"},
+    "NetworkSettings": {
+      "Networks": {
+        "bridge": {"IPAddress": "172.17.0.2", "GlobalIPv6Address": ""},
+        "app": {"IPAddress": "10.0.0.2", "GlobalIPv6Address": "fd00::2"}
+      }
+    }
+  }
+]`
+
+func TestDockerContainers(t *testing.T) {
+	var filters string
+
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v1.40/containers/json" {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"message": "page not found"})
+			return
+		}
+
+		filters = req.URL.Query().Get("filters")
+		w.Write([]byte(testDockerContainers))
+	}))
+	ts.Listener = listener
+	ts.Start()
+	defer ts.Close()
+
+	target, err := targets.New("docker_containers", map[string]interface{}{
+		"socket": socket,
+		"labels": map[string]interface{}{
+			"yak":  "web",
+			"tier": "frontend",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, err := target.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []targets.Host{
+		targets.Host{
+			Name:    "web1",
+			Address: "10.0.0.2",
+			Vars: map[string]interface{}{
+				"id":    "8dfafdbc3a40",
+				"image": "nginx:latest",
+				"labels": map[string]interface{}{
+					"yak":  "web",
+					"tier": "frontend",
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, `{"label":["tier=frontend","yak=web"]}`, filters)
+	assert.Equal(t, expected, hosts)
+
+	// The address of a specific network can be used.
+	target, err = targets.New("docker_containers", map[string]interface{}{
+		"socket":   socket,
+		"network":  "app",
+		"use_ipv6": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, err = target.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "", filters)
+	assert.Equal(t, "[fd00::2]", hosts[0].Address)
+
+	// An unreachable server is an error.
+	ts.Close()
+	_, err = target.Discover()
+	assert.Error(t, err)
+}
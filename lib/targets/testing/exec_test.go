@@ -0,0 +1,126 @@
+package testing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jtopjian/yak/lib/targets"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExec(t *testing.T) {
+	target, err := targets.New("exec", map[string]interface{}{
+		"_dir":    "fixtures",
+		"command": "./inventory.sh",
+		"env": map[string]interface{}{
+			"INVENTORY_ROLE": "web",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []targets.Host{
+		targets.Host{
+			Name:    "web1",
+			Address: "10.0.0.1",
+			Vars: map[string]interface{}{
+				"role": "web",
+			},
+		},
+		targets.Host{
+			Name:    "web2",
+			Address: "web2",
+		},
+	}
+
+	actual, err := target.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, expected, actual)
+}
+
+func TestExec_Background(t *testing.T) {
+	// A background process which holds the output of the program
+	// open doesn't block discovery.
+	target, err := targets.New("exec", map[string]interface{}{
+		"command": "sh",
+		"args":    []interface{}{"-c", "sleep 60 & echo []"},
+		"timeout": 5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	actual, err := target.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, actual)
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	// Neither does it block a program which timed out.
+	target, err = targets.New("exec", map[string]interface{}{
+		"command": "sh",
+		"args":    []interface{}{"-c", "sleep 60 & sleep 60"},
+		"timeout": 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start = time.Now()
+	_, err = target.Discover()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "sh timed out after 1 seconds")
+	}
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestExec_Errors(t *testing.T) {
+	options := map[string]interface{}{
+		"_dir":    "fixtures",
+		"command": "./inventory.sh",
+		"args":    []interface{}{"fail"},
+	}
+
+	target, err := targets.New("exec", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = target.Discover()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "inventory.sh failed: exit status 2: unable to reach the CMDB")
+	}
+
+	options["args"] = []interface{}{"sleep"}
+	options["timeout"] = 1
+	target, err = targets.New("exec", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = target.Discover()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "inventory.sh timed out after 1 seconds")
+	}
+
+	options["args"] = []interface{}{"invalid"}
+	target, err = targets.New("exec", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = target.Discover()
+	assert.Error(t, err)
+
+	// A command is required.
+	_, err = targets.New("exec", map[string]interface{}{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,152 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/jtopjian/yak/lib/targets"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFile(t *testing.T) {
+	target, err := targets.New("file", map[string]interface{}{
+		"_dir":  "fixtures",
+		"file":  "inventory.yaml",
+		"group": "web",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []targets.Host{
+		targets.Host{
+			Name:    "web1",
+			Address: "10.0.0.1",
+			Vars: map[string]interface{}{
+				"datacenter": "yyc",
+				"role":       "web",
+				"tier":       "production",
+				"weight":     10,
+			},
+		},
+		targets.Host{
+			Name:    "web2",
+			Address: "10.0.0.2",
+			Port:    2222,
+			Vars: map[string]interface{}{
+				"datacenter": "yyc",
+				"role":       "web",
+				"tier":       "production",
+				"weight":     1,
+			},
+		},
+	}
+
+	actual, err := target.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, expected, actual)
+
+	// The hosts of children are hosts of the group.
+	target, err = targets.New("file", map[string]interface{}{
+		"file":  "fixtures/inventory.yaml",
+		"group": "app",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err = target.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, host := range actual {
+		names = append(names, host.Name)
+	}
+
+	assert.Equal(t, []string{"web1", "web2", "db1", "db2"}, names)
+	assert.Equal(t, "[fd00::5]", actual[2].Address)
+	assert.Equal(t, "primary", actual[2].Vars["role"])
+	assert.Equal(t, "db2", actual[3].Address)
+	assert.Equal(t, "db", actual[3].Vars["role"])
+
+	// Without a group, every host is returned.
+	target, err = targets.New("file", map[string]interface{}{
+		"file": "fixtures/inventory.yaml",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err = target.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 4, len(actual))
+	assert.Equal(t, "db1", actual[0].Name)
+}
+
+func TestFile_JSON(t *testing.T) {
+	target, err := targets.New("file", map[string]interface{}{
+		"file":  "fixtures/inventory.json",
+		"group": "web",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []targets.Host{
+		targets.Host{
+			Name:    "web1",
+			Address: "10.0.0.1",
+			Port:    2222,
+			Vars: map[string]interface{}{
+				"role": "web",
+			},
+		},
+	}
+
+	actual, err := target.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, expected, actual)
+}
+
+func TestFile_Errors(t *testing.T) {
+	target, err := targets.New("file", map[string]interface{}{
+		"file":  "fixtures/inventory.yaml",
+		"group": "missing",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = target.Discover()
+	if assert.Error(t, err) {
+		assert.Equal(t, "unable to read inventory fixtures/inventory.yaml: unknown group: missing", err.Error())
+	}
+
+	target, err = targets.New("file", map[string]interface{}{
+		"file": "fixtures/inventory-cycle.yaml",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = target.Discover()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "is a child of itself")
+	}
+
+	_, err = targets.New("file", map[string]interface{}{
+		"file": "fixtures/missing.yaml",
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,23 @@
+#!/bin/sh
+# A dynamic inventory script for the exec target tests.
+
+case "$1" in
+  fail)
+    echo "unable to reach the CMDB" >&2
+    exit 2
+    ;;
+  sleep)
+    exec sleep 5
+    ;;
+  invalid)
+    echo "not json"
+    exit 0
+    ;;
+esac
+
+cat <<JSON
+[
+  {"name": "web1", "address": "10.0.0.1", "vars": {"role": "$INVENTORY_ROLE"}},
+  {"name": "web2"}
+]
+JSON
@@ -0,0 +1,111 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/jtopjian/yak/lib/targets"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTerraformState(t *testing.T) {
+	target, err := targets.New("terraform_state", map[string]interface{}{
+		"_dir":          "fixtures",
+		"file":          "terraform.tfstate",
+		"resource_type": "openstack_compute_instance_v2",
+		"resource_name": "mem*",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, err := target.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Equal(t, 2, len(hosts)) {
+		assert.Equal(t, "memcached-01", hosts[0].Name)
+		assert.Equal(t, "10.1.0.11", hosts[0].Address)
+		assert.Equal(t, "openstack_compute_instance_v2.memcached[0]", hosts[0].Vars["resource"])
+		assert.Equal(t, "memcached-02", hosts[1].Name)
+		assert.Equal(t, "10.1.0.12", hosts[1].Address)
+	}
+
+	// Nested attributes can be used and modules are included.
+	target, err = targets.New("terraform_state", map[string]interface{}{
+		"file":              "fixtures/terraform.tfstate",
+		"resource_type":     "openstack_compute_instance_v2",
+		"address_attribute": "network.0.fixed_ip_v4",
+		"name_attribute":    "id",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, err = target.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Equal(t, 3, len(hosts)) {
+		assert.Equal(t, "6f2ae3d4-56b7-4d55-a0b6-2b2d7f4e0a11", hosts[0].Name)
+		assert.Equal(t, "192.168.1.11", hosts[0].Address)
+		assert.Equal(t, "192.168.1.21", hosts[2].Address)
+		assert.Equal(t, `module.web.openstack_compute_instance_v2.web["blue"]`, hosts[2].Vars["resource"])
+	}
+
+	// IPv6 addresses are bracketed.
+	target, err = targets.New("terraform_state", map[string]interface{}{
+		"file":              "fixtures/terraform.tfstate",
+		"resource_name":     "memcached",
+		"resource_type":     "openstack_compute_instance_v2",
+		"address_attribute": "access_ip_v6",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, err = target.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Equal(t, 2, len(hosts)) {
+		assert.Equal(t, "[2605:fd00:4:1000:f816:3eff:fe9c:89de]", hosts[0].Address)
+	}
+}
+
+func TestTerraformState_Errors(t *testing.T) {
+	// A missing attribute is an error.
+	target, err := targets.New("terraform_state", map[string]interface{}{
+		"file":              "fixtures/terraform.tfstate",
+		"resource_name":     "web",
+		"address_attribute": "access_ip_v6",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = target.Discover()
+	if assert.Error(t, err) {
+		assert.Equal(t, `unable to get address of module.web.openstack_compute_instance_v2.web["blue"]: attribute access_ip_v6 not found`, err.Error())
+	}
+
+	// A missing state file is an error.
+	target, err = targets.New("terraform_state", map[string]interface{}{
+		"_dir": "fixtures",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = target.Discover()
+	assert.Error(t, err)
+
+	// An invalid pattern is an error.
+	_, err = targets.New("terraform_state", map[string]interface{}{
+		"resource_name": "[",
+	})
+	assert.Error(t, err)
+}
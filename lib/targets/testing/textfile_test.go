@@ -36,3 +36,63 @@ func TestTextFile(t *testing.T) {
 
 	assert.Equal(t, expected, actual)
 }
+
+func TestTextFile_Vars(t *testing.T) {
+	config := yakfile.Target{
+		Type: "textfile",
+		Options: map[string]interface{}{
+			"file": "fixtures/hosts-vars.txt",
+		},
+	}
+
+	textfile, err := targets.New(config.Type, config.Options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []targets.Host{
+		targets.Host{
+			Address: "host1.example.com",
+			Name:    "host1.example.com",
+			Vars: map[string]interface{}{
+				"role":             "web",
+				"memcached_memory": "64",
+			},
+		},
+		targets.Host{
+			Address: "host2.example.com",
+			Name:    "host2.example.com",
+			Vars: map[string]interface{}{
+				"role":        "db",
+				"description": "primary database",
+			},
+		},
+		targets.Host{Address: "192.168.100.1", Name: "192.168.100.1"},
+	}
+
+	actual, err := textfile.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, expected, actual)
+}
+
+func TestTextFile_BadVars(t *testing.T) {
+	config := yakfile.Target{
+		Type: "textfile",
+		Options: map[string]interface{}{
+			"file": "fixtures/hosts-bad-vars.txt",
+		},
+	}
+
+	textfile, err := targets.New(config.Type, config.Options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = textfile.Discover()
+	if assert.Error(t, err) {
+		assert.Equal(t, "invalid variables of host2.example.com on line 3: primary is not key=value", err.Error())
+	}
+}
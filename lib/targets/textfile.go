@@ -8,6 +8,8 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/jtopjian/yak/lib/utils"
+
 	"github.com/mitchellh/mapstructure"
 )
 
@@ -15,6 +17,10 @@ import (
 // textfile line.
 var textFileInvalidEntry = regexp.MustCompile(`[^0-9A-Za-z\-:\._\[\]]+`)
 
+// textFileVar is a regular expression to match a key=value variable
+// of a textfile line.
+var textFileVar = regexp.MustCompile(`\S+=(".*?"|<%.*?%>|\S+)`)
+
 // TextFile represents a textfile target driver.
 type TextFile struct {
 	File string `mapstructure:"file"`
@@ -56,8 +62,10 @@ func (r TextFile) Discover() ([]Host, error) {
 	defer f.Close()
 
 	var hosts []Host
+	var lineNumber int
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
+		lineNumber++
 		line := scanner.Text()
 		if strings.HasPrefix(line, "#") {
 			continue
@@ -67,10 +75,36 @@ func (r TextFile) Discover() ([]Host, error) {
 			continue
 		}
 
-		if !textFileInvalidEntry.MatchString(line) {
+		// The hostname can be followed by key=value variables.
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		name := fields[0]
+		if !textFileInvalidEntry.MatchString(name) {
 			host := Host{
-				Name:    line,
-				Address: line,
+				Name:    name,
+				Address: name,
+			}
+
+			if len(fields) > 1 {
+				rest := strings.TrimPrefix(strings.TrimSpace(line), name)
+
+				// Everything after the hostname must be a variable.
+				if v := strings.TrimSpace(textFileVar.ReplaceAllString(rest, "")); v != "" {
+					return nil, fmt.Errorf("invalid variables of %s on line %d: %s is not key=value",
+						name, lineNumber, strings.Fields(v)[0])
+				}
+
+				vars, err := utils.ParseSimplified(rest)
+				if err != nil {
+					return nil, fmt.Errorf("unable to parse variables of %s: %s", name, err)
+				}
+
+				if len(vars) > 0 {
+					host.Vars = vars
+				}
 			}
 
 			hosts = append(hosts, host)
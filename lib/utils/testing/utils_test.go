@@ -27,6 +27,14 @@ func TestUtils_parseSimplified(t *testing.T) {
 				"bar": "baz",
 			},
 		},
+		{
+			`name=<% .vars.name %> state="<% .vars.version %>" sudo=true`,
+			map[string]interface{}{
+				"name":  "<% .vars.name %>",
+				"state": "<% .vars.version %>",
+				"sudo":  "true",
+			},
+		},
 	}
 
 	for _, i := range testCases {
@@ -38,3 +46,63 @@ func TestUtils_parseSimplified(t *testing.T) {
 		assert.Equal(t, i.expected, params)
 	}
 }
+
+func TestUtils_ShellQuote(t *testing.T) {
+	testCases := []struct {
+		testCase string
+		expected string
+	}{
+		{`echo hi`, `'echo hi'`},
+		{`echo $HOME && ls`, `'echo $HOME && ls'`},
+		{`echo 'hi'`, `'echo '"'"'hi'"'"''`},
+		{``, `''`},
+	}
+
+	for _, i := range testCases {
+		assert.Equal(t, i.expected, utils.ShellQuote(i.testCase))
+	}
+}
+
+func TestUtils_ShellJoin(t *testing.T) {
+	testCases := []struct {
+		testCase []string
+		expected string
+	}{
+		{[]string{"apt-get", "install", "-y", "vim=2:8.0"}, `apt-get install -y vim=2:8.0`},
+		{[]string{"mv", "/tmp/my file", "/tmp/it's"}, `mv '/tmp/my file' '/tmp/it'"'"'s'`},
+		{[]string{"echo", "$HOME", "`id`", `"hi"`}, `echo '$HOME' '` + "`id`" + `' '"hi"'`},
+		{[]string{"echo", ""}, `echo ''`},
+	}
+
+	for _, i := range testCases {
+		assert.Equal(t, i.expected, utils.ShellJoin(i.testCase...))
+	}
+}
+
+func TestUtils_ShellScript(t *testing.T) {
+	env := map[string]string{
+		"B": "it's",
+		"A": "$HOME",
+	}
+
+	expected := `export A='$HOME'; export B='it'"'"'s'; cd '/tmp/my dir' || exit 1; echo hi`
+	actual, err := utils.ShellScript("echo hi", "/tmp/my dir", env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, expected, actual)
+
+	actual, err = utils.ShellScript("echo hi", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "echo hi", actual)
+
+	// The names of variables can't be used to inject commands.
+	for _, name := range []string{"", "1A", "A-B", "A=B", "A; rm -rf /;B", "$(id)"} {
+		_, err := utils.ShellScript("echo hi", "", map[string]string{name: "x"})
+		if assert.Error(t, err, name) {
+			assert.Equal(t, "invalid env name: "+name, err.Error())
+		}
+	}
+}
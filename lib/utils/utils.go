@@ -4,11 +4,18 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
 
-var simplifiedRe = regexp.MustCompile(`(\S+)=(".*"|<% .* %>|\S+)`)
+var simplifiedRe = regexp.MustCompile(`(\S+)=(".*?"|<%.*?%>|\S+)`)
+
+// envNameRe matches a valid environment variable name.
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// shellSafeRe matches words which a POSIX shell doesn't interpret.
+var shellSafeRe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
 
 // ParseSimplified will attempt to parse a "simplified" line such as:
 // action.name key=val key=val
@@ -30,6 +37,92 @@ func ParseSimplified(line string) (map[string]interface{}, error) {
 	return params, nil
 }
 
+// ShellQuote quotes a string so a POSIX shell treats it as a single
+// word without expanding anything in it.
+func ShellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
+
+// ShellJoin joins arguments into a command line. Arguments which
+// a shell would interpret are quoted, so each argument is passed to
+// the command as is.
+func ShellJoin(args ...string) string {
+	words := make([]string, len(args))
+	for i, arg := range args {
+		if shellSafeRe.MatchString(arg) {
+			words[i] = arg
+		} else {
+			words[i] = ShellQuote(arg)
+		}
+	}
+
+	return strings.Join(words, " ")
+}
+
+// ValidEnvName determines if a name can be used as the name of an
+// environment variable.
+func ValidEnvName(name string) bool {
+	return envNameRe.MatchString(name)
+}
+
+// ShellScript returns a shell script which runs a command in a
+// directory with environment variables. The variables are exported
+// in order of their names. If the directory doesn't exist, the
+// command is not run. The names of the variables are part of the
+// script, so an invalid name is an error.
+func ShellScript(cmd, dir string, env map[string]string) (string, error) {
+	var keys []string
+	for k := range env {
+		if !ValidEnvName(k) {
+			return "", fmt.Errorf("invalid env name: %s", k)
+		}
+
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var script strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&script, "export %s=%s; ", k, ShellQuote(env[k]))
+	}
+
+	if dir != "" {
+		fmt.Fprintf(&script, "cd %s || exit 1; ", ShellQuote(dir))
+	}
+
+	script.WriteString(cmd)
+
+	return script.String(), nil
+}
+
+// NormalizeValue will convert the map[interface{}]interface{} values
+// created by the yaml parser into map[string]interface{} so the value
+// can be used in templates and encoded as JSON.
+func NormalizeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{})
+		for key, val := range v {
+			m[fmt.Sprintf("%v", key)] = NormalizeValue(val)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{})
+		for key, val := range v {
+			m[key] = NormalizeValue(val)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, val := range v {
+			l[i] = NormalizeValue(val)
+		}
+		return l
+	}
+
+	return v
+}
+
 // ValidateTags ensures a struct field is valid by the custom tags it has.
 func ValidateTags(s interface{}) error {
 	vValue := reflect.ValueOf(s)
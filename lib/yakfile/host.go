@@ -10,14 +10,37 @@ import (
 type Host struct {
 	Name           string
 	Address        string
+	Port           int
 	TargetName     string
 	ConnectionName string
 	ConnectionType string
 	Connection     connections.Connection
 
+	// Vars are variables which the target driver
+	// discovered about the host.
+	Vars map[string]interface{}
+
 	mux sync.Mutex
 }
 
+// Data returns information about the host for use in the
+// templated input of a step.
+func (r *Host) Data() map[string]interface{} {
+	vars := r.Vars
+	if vars == nil {
+		vars = make(map[string]interface{})
+	}
+
+	return map[string]interface{}{
+		"name":       r.Name,
+		"address":    r.Address,
+		"port":       r.Port,
+		"target":     r.TargetName,
+		"connection": r.ConnectionName,
+		"vars":       vars,
+	}
+}
+
 func (r *Host) SetConnection(connName string, connInfo *Connection) error {
 	r.mux.Lock()
 	defer r.mux.Unlock()
@@ -27,15 +50,24 @@ func (r *Host) SetConnection(connName string, connInfo *Connection) error {
 		r.ConnectionType = connInfo.Type
 
 		// In order to create the connection, a target and connection
-		// must be glued together.
+		// must be glued together. The options are shared by every host
+		// of the connection, so the host is set on a copy.
+		options := make(map[string]interface{}, len(connInfo.Options))
+		for k, v := range connInfo.Options {
+			options[k] = v
+		}
+
 		switch connInfo.Type {
-		case "lxd":
-			connInfo.Options["host"] = r.Name
+		case "docker", "lxd":
+			options["host"] = r.Name
 		default:
-			connInfo.Options["host"] = r.Address
+			options["host"] = r.Address
+			if r.Port > 0 {
+				options["port"] = r.Port
+			}
 		}
 
-		conn, err := connections.New(connInfo.Type, connInfo.Options)
+		conn, err := connections.New(connInfo.Type, options)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,348 @@
+package yakfile
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// targetAll is the target expression of the hosts of every target.
+const targetAll = "_all"
+
+// targetSliceRe matches a slice of the hosts of a target, such as
+// web[0], web[0:3], or web[-2:].
+var targetSliceRe = regexp.MustCompile(`^(.+)\[(-?[0-9]+)?(?:(:)(-?[0-9]+)?)?\]$`)
+
+// targetTerm is a term of a target expression. op is '&' for an
+// intersection, '!' for an exclusion, and 0 for a union.
+type targetTerm struct {
+	op      rune
+	pattern string
+}
+
+// parseTargetExpressions will parse the terms of target expressions.
+// Terms are separated by commas, and a term starting with & or ! is
+// an intersection or an exclusion, as in web,db&prod!canary.
+func parseTargetExpressions(expressions []string) ([]targetTerm, error) {
+	var terms []targetTerm
+
+	for _, expression := range expressions {
+		var term strings.Builder
+		var op rune
+		var depth int
+		var escaped bool
+
+		flush := func() error {
+			pattern := strings.TrimSpace(term.String())
+			if pattern == "" {
+				return fmt.Errorf("invalid target expression: %s", expression)
+			}
+
+			terms = append(terms, targetTerm{op: op, pattern: pattern})
+			term.Reset()
+			op = 0
+
+			return nil
+		}
+
+		// Separators inside of brackets, braces, and parentheses are
+		// part of a slice or a regular expression. A character after
+		// a backslash is escaped, as in ~web\[, so it is never counted
+		// as a bracket or a separator.
+		for _, c := range expression {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '[' || c == '{' || c == '(':
+				depth++
+			case c == ']' || c == '}' || c == ')':
+				depth--
+			case depth > 0:
+			case c == ',':
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				continue
+			case c == '&' || c == '!':
+				if strings.TrimSpace(term.String()) != "" {
+					if err := flush(); err != nil {
+						return nil, err
+					}
+				} else if op != 0 {
+					return nil, fmt.Errorf("invalid target expression: %s", expression)
+				}
+
+				op = c
+				continue
+			}
+
+			term.WriteRune(c)
+		}
+
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	return terms, nil
+}
+
+// hostSelector selects the hosts of target expressions. The hosts of
+// each target are discovered once.
+type hostSelector struct {
+	herd  Herd
+	hosts map[string][]*Host
+
+	// all returns the hosts which host name patterns are matched
+	// against, and which are the hosts of _all.
+	all func() ([]*Host, error)
+
+	// strict is set if a name which is neither a target nor a host
+	// is an error.
+	strict bool
+}
+
+// newHostSelector will return a hostSelector of the targets of a herd.
+func newHostSelector(herd Herd) *hostSelector {
+	selector := &hostSelector{
+		herd:   herd,
+		hosts:  make(map[string][]*Host),
+		strict: true,
+	}
+
+	selector.all = func() ([]*Host, error) {
+		var names []string
+		for name := range herd.ListTargets() {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var hosts []*Host
+		for _, name := range names {
+			targetHosts, _, err := selector.targetHosts(name)
+			if err != nil {
+				return nil, err
+			}
+
+			hosts = append(hosts, targetHosts...)
+		}
+
+		return hosts, nil
+	}
+
+	return selector
+}
+
+// selectHosts returns the hosts of target expressions. The hosts of
+// every union term are selected first, or every host if there are
+// none, then the intersections and exclusions are applied.
+func (r *hostSelector) selectHosts(expressions []string) ([]*Host, error) {
+	terms, err := parseTargetExpressions(expressions)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []*Host
+	seen := make(map[*Host]bool)
+	add := func(hosts []*Host) {
+		for _, host := range hosts {
+			if !seen[host] {
+				seen[host] = true
+				selected = append(selected, host)
+			}
+		}
+	}
+
+	var unions int
+	for _, term := range terms {
+		if term.op != 0 {
+			continue
+		}
+		unions++
+
+		hosts, err := r.match(term.pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		add(hosts)
+	}
+
+	if unions == 0 {
+		hosts, err := r.all()
+		if err != nil {
+			return nil, err
+		}
+
+		add(hosts)
+	}
+
+	// Intersections and exclusions compare hosts by name, since
+	// different targets can discover the same host.
+	for _, term := range terms {
+		if term.op == 0 {
+			continue
+		}
+
+		hosts, err := r.match(term.pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		names := make(map[string]bool)
+		for _, host := range hosts {
+			names[host.Name] = true
+		}
+
+		var filtered []*Host
+		for _, host := range selected {
+			if names[host.Name] == (term.op == '&') {
+				filtered = append(filtered, host)
+			}
+		}
+
+		selected = filtered
+	}
+
+	return selected, nil
+}
+
+// match returns the hosts of a single pattern. A pattern is the name
+// of a target, a slice of the hosts of a target, a regular expression
+// starting with ~, a glob, or the name of a host.
+func (r *hostSelector) match(pattern string) ([]*Host, error) {
+	if hosts, ok, err := r.targetHosts(pattern); ok || err != nil {
+		return hosts, err
+	}
+
+	if m := targetSliceRe.FindStringSubmatch(pattern); m != nil && (m[2] != "" || m[3] != "") {
+		if hosts, ok, err := r.targetHosts(m[1]); ok || err != nil {
+			if err != nil {
+				return nil, err
+			}
+
+			return sliceHosts(hosts, m[2], m[3] != "", m[4]), nil
+		}
+	}
+
+	all, err := r.all()
+	if err != nil {
+		return nil, err
+	}
+
+	var plain bool
+	var matches func(name string) bool
+	switch {
+	case strings.HasPrefix(pattern, "~"):
+		re, err := regexp.Compile(pattern[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid target pattern %s: %s", pattern, err)
+		}
+		matches = re.MatchString
+	case strings.ContainsAny(pattern, "*?["):
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid target pattern %s: %s", pattern, err)
+		}
+		matches = func(name string) bool {
+			ok, _ := path.Match(pattern, name)
+			return ok
+		}
+	default:
+		plain = true
+		matches = func(name string) bool {
+			return name == pattern
+		}
+	}
+
+	var hosts []*Host
+	for _, host := range all {
+		if matches(host.Name) {
+			hosts = append(hosts, host)
+		}
+	}
+
+	if len(hosts) == 0 && plain && r.strict {
+		return nil, fmt.Errorf("target %s not found", pattern)
+	}
+
+	return hosts, nil
+}
+
+// targetHosts returns the hosts of a target, discovering them if
+// needed. ok is false if there is no such target.
+func (r *hostSelector) targetHosts(name string) (hosts []*Host, ok bool, err error) {
+	if name == targetAll {
+		hosts, err := r.all()
+		return hosts, true, err
+	}
+
+	if hosts, ok := r.hosts[name]; ok {
+		return hosts, true, nil
+	}
+
+	target, err := r.herd.GetTarget(name)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	discoveredHosts, err := target.DiscoverHosts()
+	if err != nil {
+		return nil, true, err
+	}
+
+	for i := range discoveredHosts {
+		hosts = append(hosts, &discoveredHosts[i])
+	}
+
+	r.hosts[name] = hosts
+
+	return hosts, true, nil
+}
+
+// sliceHosts returns a slice of hosts. As with Go slices, the end is
+// not included, and negative indexes count from the end.
+func sliceHosts(hosts []*Host, start string, isRange bool, end string) []*Host {
+	index := func(v string, def int) int {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return def
+		}
+
+		if i < 0 {
+			i += len(hosts)
+		}
+
+		return i
+	}
+
+	if !isRange {
+		i := index(start, 0)
+		if i < 0 || i >= len(hosts) {
+			return nil
+		}
+
+		return hosts[i : i+1]
+	}
+
+	from := index(start, 0)
+	to := index(end, len(hosts))
+
+	if from < 0 {
+		from = 0
+	}
+
+	if to > len(hosts) {
+		to = len(hosts)
+	}
+
+	if from >= to {
+		return nil
+	}
+
+	return hosts[from:to]
+}
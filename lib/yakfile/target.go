@@ -74,6 +74,8 @@ func (r *Target) DiscoverHosts() ([]Host, error) {
 			TargetName: r.Name,
 			Name:       host.Name,
 			Address:    host.Address,
+			Port:       host.Port,
+			Vars:       host.Vars,
 		})
 	}
 
@@ -2,6 +2,7 @@ package yakfile
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/jtopjian/yak/lib/utils"
@@ -34,7 +35,7 @@ func (r *Task) UnmarshalYAML(unmarshal func(interface{}) error) error {
 
 	// Apply defaults to all steps in the task.
 	for i, step := range r.Steps {
-		if step.Targets[0] == "_all" {
+		if step.Targets[0] == targetAll {
 			if r.Defaults.Targets != nil {
 				r.Steps[i].Targets = r.Defaults.Targets
 			}
@@ -44,10 +45,29 @@ func (r *Task) UnmarshalYAML(unmarshal func(interface{}) error) error {
 			step.Limit = r.Defaults.Limit
 		}
 
-		if _, ok := step.Input["sudo"]; !ok {
-			if r.Defaults.Sudo != nil {
-				step.Input["sudo"] = *r.Defaults.Sudo
-			}
+		if r.Defaults.Sudo != nil {
+			r.Steps[i].setDefaultInput("sudo", *r.Defaults.Sudo)
+		}
+
+		if r.Defaults.Become != nil {
+			r.Steps[i].setDefaultInput("become", *r.Defaults.Become)
+		}
+
+		if r.Defaults.BecomeAuth != "" {
+			r.Steps[i].setDefaultInput("become_auth", r.Defaults.BecomeAuth)
+		}
+
+		if r.Defaults.BecomeMethod != "" {
+			r.Steps[i].setDefaultInput("become_method", r.Defaults.BecomeMethod)
+		}
+
+		if r.Defaults.BecomeUser != "" {
+			r.Steps[i].setDefaultInput("become_user", r.Defaults.BecomeUser)
+		}
+
+		// A step can combine its own become options with the defaults.
+		if err := r.Steps[i].validateBecome(); err != nil {
+			return err
 		}
 	}
 
@@ -60,6 +80,21 @@ type TaskDefaults struct {
 	Limit   int      `yaml:"limit" default:"5"`
 	Sudo    *bool    `yaml:"sudo"`
 	Targets []string `yaml:"targets"`
+
+	// Become, BecomeAuth, BecomeMethod, and BecomeUser are applied
+	// to the input of each step to run actions as another user.
+	Become       *bool  `yaml:"become"`
+	BecomeAuth   string `yaml:"become_auth"`
+	BecomeMethod string `yaml:"become_method"`
+	BecomeUser   string `yaml:"become_user"`
+
+	// AnyErrorsFatal will stop the task after a step has failed
+	// on any host.
+	AnyErrorsFatal bool `yaml:"any_errors_fatal"`
+
+	// MaxFailPercentage will stop the task after the percentage
+	// of hosts which failed a step is greater than the value.
+	MaxFailPercentage *int `yaml:"max_fail_percentage"`
 }
 
 // UnmarshalYAML is a custom unmarshaler to help initialize and
@@ -81,18 +116,40 @@ func (r *TaskDefaults) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return err
 	}
 
+	if v := r.MaxFailPercentage; v != nil && (*v < 0 || *v > 100) {
+		return fmt.Errorf("max_fail_percentage must be between 0 and 100: %d", *v)
+	}
+
+	if err := ValidateBecome(r.BecomeMethod, r.BecomeAuth != ""); err != nil {
+		return err
+	}
+
+	if _, err := parseTargetExpressions(r.Targets); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// registerRe matches a valid register name.
+var registerRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 // Step represents the structure of a step within a task.
 type Step struct {
-	Action  string                 `yaml:"action" required:"true"`
-	Input   map[string]interface{} `yaml:"input"`
-	Limit   int                    `yaml:"limit"`
-	Name    string                 `yaml:"name" required:"true"`
-	Notify  string                 `yaml:"notify"`
-	Targets []string               `yaml:"targets"`
-	Timeout int                    `yaml:"timeout"`
+	Action       string                 `yaml:"action" required:"true"`
+	IgnoreErrors bool                   `yaml:"ignore_errors"`
+	Input        map[string]interface{} `yaml:"input"`
+	Limit        int                    `yaml:"limit"`
+	Loop         interface{}            `yaml:"loop"`
+	Name         string                 `yaml:"name" required:"true"`
+	Notify       string                 `yaml:"notify"`
+	Register     string                 `yaml:"register"`
+	Targets      []string               `yaml:"targets"`
+	Timeout      int                    `yaml:"timeout"`
+	When         string                 `yaml:"when"`
+
+	// WithItems is an alias of Loop.
+	WithItems interface{} `yaml:"with_items"`
 }
 
 // UnmarshalYAML is a custom unmarshaler to help initialize and
@@ -126,10 +183,81 @@ func (r *Step) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		r.Input = params
 	}
 
+	// A registered result is referenced in a template by name,
+	// so the name must be a valid identifier.
+	if r.Register != "" && !registerRe.MatchString(r.Register) {
+		return fmt.Errorf("invalid register name for step %s: %s", r.Name, r.Register)
+	}
+
+	// with_items is an alias of loop.
+	if r.WithItems != nil {
+		if r.Loop != nil {
+			return fmt.Errorf("only one of loop and with_items can be used for step %s", r.Name)
+		}
+
+		r.Loop = r.WithItems
+		r.WithItems = nil
+	}
+	r.Loop = utils.NormalizeValue(r.Loop)
+
 	// If no targets were specified, add an entry for all.
 	if len(r.Targets) == 0 {
-		r.Targets = []string{"_all"}
+		r.Targets = []string{targetAll}
+	}
+
+	if _, err := parseTargetExpressions(r.Targets); err != nil {
+		return fmt.Errorf("invalid targets for step %s: %s", r.Name, err)
+	}
+
+	if err := r.validateBecome(); err != nil {
+		return err
 	}
 
 	return nil
 }
+
+// validateBecome ensures the become options in the input of a step
+// are valid. Templated options are validated when the step is run.
+func (r *Step) validateBecome() error {
+	method, _ := r.Input["become_method"].(string)
+	auth, _ := r.Input["become_auth"].(string)
+
+	if strings.Contains(method, "<%") || strings.Contains(auth, "<%") {
+		return nil
+	}
+
+	if err := ValidateBecome(method, auth != ""); err != nil {
+		return fmt.Errorf("invalid become options for step %s: %s", r.Name, err)
+	}
+
+	return nil
+}
+
+// ValidateBecome ensures a become method is supported. sudo and su
+// read a password from stdin. doas only reads a password from a
+// terminal, so it can't be used with a password.
+func ValidateBecome(method string, password bool) error {
+	switch method {
+	case "", "sudo", "su":
+	case "doas":
+		if password {
+			return fmt.Errorf("become_auth can't be used with become_method doas, doas only reads a password from a terminal")
+		}
+	default:
+		return fmt.Errorf("unsupported become_method: %s", method)
+	}
+
+	return nil
+}
+
+// setDefaultInput will set an input of a step if the step did not
+// set it.
+func (r *Step) setDefaultInput(key string, value interface{}) {
+	if r.Input == nil {
+		r.Input = make(map[string]interface{})
+	}
+
+	if _, ok := r.Input[key]; !ok {
+		r.Input[key] = value
+	}
+}
@@ -0,0 +1,227 @@
+package yakfile
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Template delimiters. These are the same delimiters which
+// utils.ParseSimplified recognizes.
+const (
+	templateLeftDelim  = "<%"
+	templateRightDelim = "%>"
+)
+
+// templateValueRe matches a template which consists of only a single
+// variable reference, such as <% .vars.packages %>.
+var templateValueRe = regexp.MustCompile(`^<%\s*(\.[\w.]+)\s*%>$`)
+
+// Render will return a copy of the step where every string in the
+// step's input has been rendered as a template with the given data.
+func (r Step) Render(data map[string]interface{}) (Step, error) {
+	input, err := renderValue(r.Input, data)
+	if err != nil {
+		return r, fmt.Errorf("unable to render input of step %s: %s", r.Name, err)
+	}
+
+	r.Input = input.(map[string]interface{})
+
+	return r, nil
+}
+
+// EvaluateWhen will evaluate the step's when condition with the
+// given data. A step without a condition is always run.
+//
+// The condition can either be a template, such as
+// <% eq .facts.os.id "ubuntu" %>, or the contents of a template
+// without the delimiters.
+func (r Step) EvaluateWhen(data map[string]interface{}) (bool, error) {
+	when := strings.TrimSpace(r.When)
+	if when == "" {
+		return true, nil
+	}
+
+	if !strings.Contains(when, templateLeftDelim) {
+		when = fmt.Sprintf("%s %s %s", templateLeftDelim, when, templateRightDelim)
+	}
+
+	v, err := renderString(when, data)
+	if err != nil {
+		return false, fmt.Errorf("unable to evaluate when of step %s: %s", r.Name, err)
+	}
+
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case string:
+		switch s := strings.TrimSpace(v); s {
+		case "", "<no value>":
+			return false, nil
+		default:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return false, fmt.Errorf("when of step %s is not a boolean: %s", r.Name, s)
+			}
+
+			return b, nil
+		}
+	case nil:
+		return false, nil
+	}
+
+	return false, fmt.Errorf("when of step %s is not a boolean: %v", r.Name, v)
+}
+
+// LoopItems will return the items the step should be run with.
+// If the step does not have a loop, nil is returned.
+//
+// The loop can either be a list, where each item is rendered with
+// the given data, or a template which refers to a list, such as
+// <% .vars.packages %>. As with when, the delimiters are optional.
+func (r Step) LoopItems(data map[string]interface{}) ([]interface{}, error) {
+	if r.Loop == nil {
+		return nil, nil
+	}
+
+	loop := r.Loop
+	if v, ok := loop.(string); ok {
+		v = strings.TrimSpace(v)
+		if !strings.Contains(v, templateLeftDelim) {
+			v = fmt.Sprintf("%s %s %s", templateLeftDelim, v, templateRightDelim)
+		}
+		loop = v
+	}
+
+	items, err := renderValue(loop, data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to render loop of step %s: %s", r.Name, err)
+	}
+
+	switch items := items.(type) {
+	case []interface{}:
+		return items, nil
+	case []string:
+		l := make([]interface{}, len(items))
+		for i, item := range items {
+			l[i] = item
+		}
+		return l, nil
+	}
+
+	return nil, fmt.Errorf("loop of step %s is not a list: %v", r.Name, items)
+}
+
+// renderValue will recursively render a value.
+// Maps and slices are copied so the original value is not modified.
+func renderValue(v interface{}, data map[string]interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return renderString(v, data)
+
+	case map[string]interface{}:
+		if v == nil {
+			return v, nil
+		}
+
+		m := make(map[string]interface{})
+		for key, val := range v {
+			rendered, err := renderValue(val, data)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = rendered
+		}
+		return m, nil
+
+	case map[interface{}]interface{}:
+		m := make(map[interface{}]interface{})
+		for key, val := range v {
+			rendered, err := renderValue(val, data)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = rendered
+		}
+		return m, nil
+
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, val := range v {
+			rendered, err := renderValue(val, data)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = rendered
+		}
+		return l, nil
+
+	case []string:
+		l := make([]interface{}, len(v))
+		for i, val := range v {
+			rendered, err := renderValue(val, data)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = rendered
+		}
+		return l, nil
+	}
+
+	return v, nil
+}
+
+// renderString will render a string as a template.
+//
+// If the string is only a reference to a single variable, the value
+// of the variable is returned as-is. This allows lists, maps, and
+// booleans to be passed to an action's input.
+func renderString(s string, data map[string]interface{}) (interface{}, error) {
+	if !strings.Contains(s, templateLeftDelim) {
+		return s, nil
+	}
+
+	if v := templateValueRe.FindStringSubmatch(s); v != nil {
+		if val, ok := lookupValue(data, v[1]); ok {
+			return val, nil
+		}
+	}
+
+	tmpl, err := template.New("input").
+		Delims(templateLeftDelim, templateRightDelim).
+		Option("missingkey=error").
+		Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	return buf.String(), nil
+}
+
+// lookupValue will return the value of a dotted path, such as
+// .vars.packages, from the given data.
+func lookupValue(data map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = data
+
+	for _, part := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		current, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
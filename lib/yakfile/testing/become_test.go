@@ -0,0 +1,41 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/jtopjian/yak/lib/yakfile"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTask_BecomeDefaults(t *testing.T) {
+	herd, err := yakfile.NewHerd([]string{"fixtures/become.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task, err := herd.GetTask("become")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []map[string]interface{}{
+		{
+			"cmd":         "createdb yak",
+			"become":      true,
+			"become_auth": "postgres-sudo",
+			"become_user": "postgres",
+		},
+		{
+			"cmd":           "service postgresql restart",
+			"become":        true,
+			"become_auth":   "",
+			"become_method": "su",
+			"become_user":   "root",
+		},
+	}
+
+	for i, step := range task.Steps {
+		assert.Equal(t, expected[i], step.Input)
+	}
+}
@@ -0,0 +1,29 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/jtopjian/yak/lib/yakfile"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHerd_GetTask(t *testing.T) {
+	herd, err := yakfile.NewHerd([]string{"fixtures/failure.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task, err := herd.GetTask("failure")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, true, task.Defaults.AnyErrorsFatal)
+	assert.Equal(t, 25, *task.Defaults.MaxFailPercentage)
+	assert.Equal(t, true, task.Steps[0].IgnoreErrors)
+	assert.Equal(t, false, task.Steps[1].IgnoreErrors)
+
+	_, err = herd.GetTask("foobar")
+	assert.Equal(t, "task foobar not found", err.Error())
+}
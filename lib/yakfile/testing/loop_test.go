@@ -0,0 +1,83 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/jtopjian/yak/lib/yakfile"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStep_LoopItems(t *testing.T) {
+	herd, err := yakfile.NewHerd([]string{"fixtures/loop.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars, err := herd.GetVars()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps, err := herd.ListStepsForTask("loop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]interface{}{
+		"vars": vars,
+	}
+
+	expected := []interface{}{
+		"memcached",
+		"libmemcached-tools",
+	}
+
+	items, err := steps[0].LoopItems(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, expected, items)
+
+	items, err = steps[1].LoopItems(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, expected, items)
+
+	// with_items is an alias of loop.
+	items, err = steps[2].LoopItems(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 2, len(items))
+
+	data["item"] = items[1]
+	step, err := steps[2].Render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "useradd -s /bin/sh bob", step.Input["cmd"])
+
+	// A step without a loop has no items.
+	step = yakfile.Step{
+		Name: "no loop",
+	}
+
+	items, err = step.LoopItems(data)
+	assert.Nil(t, err)
+	assert.Nil(t, items)
+
+	// A loop must be a list.
+	step = yakfile.Step{
+		Name: "bad loop",
+		Loop: "index .vars.packages 0",
+	}
+
+	_, err = step.LoopItems(data)
+	assert.Error(t, err)
+}
@@ -0,0 +1,174 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/jtopjian/yak/lib/yakfile"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hostNames(hosts []yakfile.Host) []string {
+	names := []string{}
+	for i := range hosts {
+		names = append(names, hosts[i].TargetName+"/"+hosts[i].Name)
+	}
+
+	return names
+}
+
+func TestHerd_GetHostsForStep(t *testing.T) {
+	herd, err := yakfile.NewHerd([]string{"fixtures/pattern.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		targets  []string
+		expected []string
+	}{
+		{[]string{"web"}, []string{"web/web1", "web/web2", "web/web3", "web/web4", "web/canary1"}},
+		{[]string{"web", "db"}, []string{"web/web1", "web/web2", "web/web3", "web/web4", "web/canary1", "db/db1", "db/db2"}},
+		{[]string{"web,db,web"}, []string{"web/web1", "web/web2", "web/web3", "web/web4", "web/canary1", "db/db1", "db/db2"}},
+		{[]string{"web&prod"}, []string{"web/web1", "web/web2"}},
+		{[]string{"web", "!canary1"}, []string{"web/web1", "web/web2", "web/web3", "web/web4"}},
+		{[]string{"web!prod!canary*"}, []string{"web/web3", "web/web4"}},
+		{[]string{"web[0:2]"}, []string{"web/web1", "web/web2"}},
+		{[]string{"web[1]"}, []string{"web/web2"}},
+		{[]string{"web[-2:]"}, []string{"web/web4", "web/canary1"}},
+		{[]string{"web[9]"}, []string{}},
+		{[]string{"db2"}, []string{"db/db2"}},
+		{[]string{"db*"}, []string{"db/db1", "db/db2", "prod/db1"}},
+		{[]string{`~^web[13]$`}, []string{"prod/web1", "web/web1", "web/web3"}},
+		{[]string{`~^web\d{1,2}$&prod`}, []string{"prod/web1", "prod/web2", "web/web1", "web/web2"}},
+		{[]string{`~^web\[?1,db2`}, []string{"prod/web1", "web/web1", "db/db2"}},
+		{[]string{`web\[1\]*,db1`}, []string{"db/db1", "prod/db1"}},
+		{[]string{"_all&db"}, []string{"db/db1", "db/db2", "prod/db1"}},
+		{[]string{"!web"}, []string{"db/db1", "db/db2", "prod/db1"}},
+	}
+
+	for _, test := range tests {
+		step := yakfile.Step{
+			Name:    "test",
+			Targets: test.targets,
+		}
+
+		hosts, err := herd.GetHostsForStep(step)
+		if err != nil {
+			t.Fatalf("%v: %s", test.targets, err)
+		}
+
+		assert.Equal(t, test.expected, hostNames(hosts), test.targets)
+
+		for i := range hosts {
+			assert.Equal(t, "local", hosts[i].ConnectionName, test.targets)
+		}
+	}
+
+	// The targets of a step in a yakfile are target expressions.
+	steps, err := herd.ListStepsForTask("pattern")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, err := herd.GetHostsForStep(steps[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 10, len(hosts))
+
+	hosts, err = herd.GetHostsForStep(steps[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"web/web1"}, hostNames(hosts))
+}
+
+func TestHerd_GetHostsForStep_Errors(t *testing.T) {
+	herd, err := yakfile.NewHerd([]string{"fixtures/pattern.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		targets  []string
+		expected string
+	}{
+		{[]string{"missing"}, "target missing not found"},
+		{[]string{"web", "!missing"}, "target missing not found"},
+		{[]string{"web,,db"}, "invalid target expression: web,,db"},
+		{[]string{"web&!db"}, "invalid target expression: web&!db"},
+		{[]string{"~web("}, "invalid target pattern ~web(: error parsing regexp: missing closing ): `web(`"},
+	}
+
+	for _, test := range tests {
+		step := yakfile.Step{
+			Name:    "test",
+			Targets: test.targets,
+		}
+
+		_, err := herd.GetHostsForStep(step)
+		if assert.Error(t, err, test.targets) {
+			assert.Equal(t, test.expected, err.Error(), test.targets)
+		}
+	}
+}
+
+func TestHerd_GetHostsForSteps(t *testing.T) {
+	herd, err := yakfile.NewHerd([]string{"fixtures/pattern.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []yakfile.Step{
+		{Name: "web", Targets: []string{"web"}},
+		{Name: "db", Targets: []string{"db"}},
+	}
+
+	tests := []struct {
+		limit    string
+		expected [][]string
+	}{
+		{"", [][]string{{"web/web1", "web/web2", "web/web3", "web/web4", "web/canary1"}, {"db/db1", "db/db2"}}},
+		{"prod", [][]string{{"web/web1", "web/web2"}, {"db/db1"}}},
+		{"!canary1", [][]string{{"web/web1", "web/web2", "web/web3", "web/web4"}, {"db/db1", "db/db2"}}},
+		{"web[0:2],web4", [][]string{{"web/web1", "web/web2", "web/web4"}, {}}},
+		{"db", [][]string{{}, {"db/db1", "db/db2"}}},
+		{"db1", [][]string{{}, {"db/db1"}}},
+		{"web*!prod", [][]string{{"web/web3", "web/web4"}, {}}},
+	}
+
+	for _, test := range tests {
+		hosts, err := herd.GetHostsForSteps(steps, test.limit)
+		if err != nil {
+			t.Fatalf("%s: %s", test.limit, err)
+		}
+
+		var actual [][]string
+		for _, h := range hosts {
+			actual = append(actual, hostNames(h))
+		}
+
+		assert.Equal(t, test.expected, actual, test.limit)
+	}
+
+	// A limit which doesn't select a host of the task is an error, so
+	// a misspelled limit never runs a task on no hosts.
+	errTests := []struct {
+		limit    string
+		expected string
+	}{
+		{"web,,db", "invalid target expression: web,,db"},
+		{"wbe1", "limit wbe1: wbe1 does not match any host of the task"},
+		{"web1,dbb*", "limit web1,dbb*: dbb* does not match any host of the task"},
+		{"web,!~^canary2$", "limit web,!~^canary2$: ~^canary2$ does not match any host of the task"},
+		{"web&db", "limit web&db does not select any host of the task"},
+	}
+
+	for _, test := range errTests {
+		_, err := herd.GetHostsForSteps(steps, test.limit)
+		if assert.Error(t, err, test.limit) {
+			assert.Equal(t, test.expected, err.Error(), test.limit)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/jtopjian/yak/lib/yakfile"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStep_Register(t *testing.T) {
+	herd, err := yakfile.NewHerd([]string{"fixtures/register.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps, err := herd.ListStepsForTask("register")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "token", steps[0].Register)
+	assert.Equal(t, "", steps[1].Register)
+
+	data := map[string]interface{}{
+		"registered": map[string]interface{}{
+			"token": map[string]interface{}{
+				"changed":   true,
+				"exit_code": 0,
+				"failed":    false,
+				"skipped":   false,
+				"stderr":    "",
+				"stdout":    "8f14e45fceea167a",
+			},
+		},
+	}
+
+	run, err := steps[1].EvaluateWhen(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, true, run)
+
+	step, err := steps[1].Render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"cmd": "echo 8f14e45fceea167a > /etc/token",
+	}
+
+	assert.Equal(t, expected, step.Input)
+}
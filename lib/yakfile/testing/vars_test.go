@@ -0,0 +1,133 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/jtopjian/yak/lib/yakfile"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHerd_GetVars(t *testing.T) {
+	herd, err := yakfile.NewHerd([]string{"fixtures/vars.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"install_dir": "/opt/memcached",
+		"version":     "1.4.25",
+		"user":        "memcached",
+		"packages": []interface{}{
+			"memcached",
+			"libmemcached-tools",
+		},
+		"limits": map[string]interface{}{
+			"memory": 64,
+		},
+	}
+
+	actual, err := herd.GetVars()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, expected, actual)
+}
+
+func TestStep_Render(t *testing.T) {
+	herd, err := yakfile.NewHerd([]string{"fixtures/vars.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars, err := herd.GetVars()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps, err := herd.ListStepsForTask("vars")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]interface{}{
+		"vars": vars,
+	}
+
+	step, err := steps[0].Render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"name":  "memcached",
+		"state": "1.4.25",
+	}
+
+	assert.Equal(t, expected, step.Input)
+
+	step, err = steps[1].Render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = map[string]interface{}{
+		"name": []interface{}{
+			"memcached",
+			"libmemcached-tools",
+		},
+		"options": []interface{}{
+			"/opt/memcached/bin",
+		},
+	}
+
+	assert.Equal(t, expected, step.Input)
+
+	// The original step must not be modified.
+	assert.Equal(t, "<% .vars.packages %>", steps[1].Input["name"])
+
+	// Missing variables are an error.
+	step = yakfile.Step{
+		Name: "missing",
+		Input: map[string]interface{}{
+			"name": "<% .vars.missing %>-dev",
+		},
+	}
+
+	_, err = step.Render(data)
+	assert.Error(t, err)
+}
+
+func TestStep_RenderHost(t *testing.T) {
+	host := yakfile.Host{
+		Name:       "host1.example.com",
+		Address:    "192.168.100.1",
+		TargetName: "textfile",
+		Vars: map[string]interface{}{
+			"role": "web",
+		},
+	}
+
+	data := map[string]interface{}{
+		"host": host.Data(),
+	}
+
+	step := yakfile.Step{
+		Name: "configure",
+		Input: map[string]interface{}{
+			"cmd": "configure --role <% .host.vars.role %> --listen <% .host.address %>",
+		},
+	}
+
+	step, err := step.Render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"cmd": "configure --role web --listen 192.168.100.1",
+	}
+
+	assert.Equal(t, expected, step.Input)
+}
@@ -0,0 +1,86 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/jtopjian/yak/lib/yakfile"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStep_EvaluateWhen(t *testing.T) {
+	data := map[string]interface{}{
+		"facts": map[string]interface{}{
+			"os": map[string]interface{}{
+				"id":         "ubuntu",
+				"version_id": "16.04",
+			},
+		},
+		"vars": map[string]interface{}{
+			"enabled": false,
+			"role":    "web",
+		},
+		"steps": map[string]interface{}{
+			"install memcached": map[string]interface{}{
+				"changed": true,
+				"failed":  false,
+				"skipped": false,
+			},
+		},
+	}
+
+	tests := []struct {
+		when     string
+		expected bool
+	}{
+		{"", true},
+		{`eq .facts.os.id "ubuntu"`, true},
+		{`<% eq .facts.os.id "centos" %>`, false},
+		{`.vars.enabled`, false},
+		{`<% not .vars.enabled %>`, true},
+		{`and (eq .vars.role "web") (ne .facts.os.version_id "14.04")`, true},
+		{`(index .steps "install memcached").changed`, true},
+		{`true`, true},
+		{`0`, false},
+	}
+
+	for _, test := range tests {
+		step := yakfile.Step{
+			Name: "test",
+			When: test.when,
+		}
+
+		actual, err := step.EvaluateWhen(data)
+		if err != nil {
+			t.Fatalf("%s: %s", test.when, err)
+		}
+
+		assert.Equal(t, test.expected, actual, test.when)
+	}
+
+	// Missing variables and non-boolean results are an error.
+	for _, when := range []string{".vars.missing", ".vars.role"} {
+		step := yakfile.Step{
+			Name: "test",
+			When: when,
+		}
+
+		_, err := step.EvaluateWhen(data)
+		assert.Error(t, err, when)
+	}
+}
+
+func TestStep_When(t *testing.T) {
+	herd, err := yakfile.NewHerd([]string{"fixtures/when.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps, err := herd.ListStepsForTask("when")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, `eq .facts.os.id "ubuntu"`, steps[0].When)
+	assert.Equal(t, "", steps[1].When)
+}
@@ -13,7 +13,7 @@ var iTrue = true
 
 var expectedSample = yakfile.Yakfile{
 	Dir: "fixtures",
-	Vars: map[string]string{
+	Vars: map[string]interface{}{
 		"foo": "bar",
 		"bar": "baz",
 	},
@@ -218,6 +218,34 @@ func TestBadYakfiles(t *testing.T) {
 			[]string{"fixtures/bad-missing-notifier.yaml"},
 			"missing notifier: foobar",
 		},
+		{
+			[]string{"fixtures/vars.yaml", "fixtures/bad-duplicate-var.yaml"},
+			"duplicate var detected: version",
+		},
+		{
+			[]string{"fixtures/bad-loop.yaml"},
+			"unable to parse YAML in fixtures/bad-loop.yaml: unable to parse YAML: only one of loop and with_items can be used for step install packages",
+		},
+		{
+			[]string{"fixtures/bad-become-method.yaml"},
+			"unable to parse YAML in fixtures/bad-become-method.yaml: unable to parse YAML: unsupported become_method: pbrun",
+		},
+		{
+			[]string{"fixtures/bad-become-step.yaml"},
+			"unable to parse YAML in fixtures/bad-become-step.yaml: unable to parse YAML: invalid become options for step create database: unsupported become_method: pbrun",
+		},
+		{
+			[]string{"fixtures/bad-become-password.yaml"},
+			"unable to parse YAML in fixtures/bad-become-password.yaml: invalid become options for step restart postgres: become_auth can't be used with become_method doas, doas only reads a password from a terminal",
+		},
+		{
+			[]string{"fixtures/bad-max-fail-percentage.yaml"},
+			"unable to parse YAML in fixtures/bad-max-fail-percentage.yaml: unable to parse YAML: max_fail_percentage must be between 0 and 100: 150",
+		},
+		{
+			[]string{"fixtures/bad-register.yaml"},
+			"unable to parse YAML in fixtures/bad-register.yaml: unable to parse YAML: invalid register name for step generate token: generated-token",
+		},
 	}
 
 	for _, v := range testCases {
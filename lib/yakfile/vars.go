@@ -0,0 +1,69 @@
+package yakfile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/jtopjian/yak/lib/utils"
+
+	"gopkg.in/yaml.v2"
+)
+
+// GetVars returns the variables of a Herd.
+//
+// Varfiles are read first, in the order the yakfiles and varfiles
+// were specified. A variable in a varfile overrides the same variable
+// in an earlier varfile. Vars defined directly in a yakfile are
+// applied last and override any varfile. Vars must be unique to the
+// Herd.
+func (r Herd) GetVars() (map[string]interface{}, error) {
+	vars := make(map[string]interface{})
+
+	for _, yak := range r {
+		for _, varfile := range yak.Varfiles {
+			v, err := readVarfile(yak.Dir, varfile)
+			if err != nil {
+				return nil, err
+			}
+
+			for key, val := range v {
+				vars[key] = val
+			}
+		}
+	}
+
+	for _, yak := range r {
+		for key, val := range yak.Vars {
+			vars[key] = utils.NormalizeValue(val)
+		}
+	}
+
+	return vars, nil
+}
+
+// readVarfile will read a yaml file of variables. If the file is
+// not an absolute path, it is read relative to the yakfile dir.
+func readVarfile(dir, file string) (map[string]interface{}, error) {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+
+	yamlFile, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read varfile %s: %s", file, err)
+	}
+
+	var v map[string]interface{}
+	err = yaml.Unmarshal(yamlFile, &v)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse YAML in %s: %s", file, err)
+	}
+
+	vars := make(map[string]interface{})
+	for key, val := range v {
+		vars[key] = utils.NormalizeValue(val)
+	}
+
+	return vars, nil
+}
@@ -16,12 +16,12 @@ var taskNameRegex = regexp.MustCompile(`task::(\w)`)
 
 // Yakfile represents the structure of a yak manifest.
 type Yakfile struct {
-	Vars        map[string]string     `yaml:"vars"`
-	Varfiles    []string              `yaml:"varfiles"`
-	Notifiers   []Step                `yaml:"notifiers"`
-	Targets     map[string]Target     `yaml:"targets"`
-	Connections map[string]Connection `yaml:"connections"`
-	Tasks       map[string]Task       `yaml:",inline"`
+	Vars        map[string]interface{} `yaml:"vars"`
+	Varfiles    []string               `yaml:"varfiles"`
+	Notifiers   []Step                 `yaml:"notifiers"`
+	Targets     map[string]Target      `yaml:"targets"`
+	Connections map[string]Connection  `yaml:"connections"`
+	Tasks       map[string]Task        `yaml:",inline"`
 
 	// Dir is meant for internal use only.
 	// It is publicly accessible only for testing.
@@ -58,12 +58,21 @@ func (r Herd) ParseAndValidate() error {
 	stepNames := make(map[string]bool)
 	targetNames := make(map[string]bool)
 	connNames := make(map[string]bool)
+	varNames := make(map[string]bool)
 
 	for i, yak := range r {
 		if err := utils.ValidateTags(yak); err != nil {
 			return err
 		}
 
+		// ensure there are no duplicate vars.
+		for name := range yak.Vars {
+			if _, ok := varNames[name]; ok {
+				return fmt.Errorf("duplicate var detected: %s", name)
+			}
+			varNames[name] = true
+		}
+
 		// ensure there are no duplicate notifiers.
 		for _, n := range yak.Notifiers {
 			if _, ok := notifiers[n.Name]; ok {
@@ -165,45 +174,175 @@ func (r Herd) GetConnection(targetName string) (string, *Connection, error) {
 
 // GetHostsForStep will discover hosts for a given step and then determine
 // their connection configuration. The hosts which are targeted by the step
-// will be returned.
+// will be returned. The targets of a step are target expressions, which
+// are described in pattern.go.
 func (r Herd) GetHostsForStep(step Step) ([]Host, error) {
 	var hosts []Host
-	t := make(map[string]Target)
 
-	for _, targetName := range step.Targets {
-		switch targetName {
-		case "_all":
-			t = r.ListTargets()
-		default:
-			targetInfo, err := r.GetTarget(targetName)
-			if err != nil {
-				return nil, err
-			}
-			t[targetName] = *targetInfo
+	selected, err := newHostSelector(r).selectHosts(step.Targets)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, host := range selected {
+		connName, connInfo, err := r.GetConnection(host.TargetName)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := host.SetConnection(connName, connInfo); err != nil {
+			return nil, err
+		}
+
+		hosts = append(hosts, *host)
+	}
+
+	return hosts, nil
+}
+
+// GetHostsForSteps returns the hosts of each step of a run. If a limit
+// was given, such as the --limit of a run, only the hosts which are
+// also selected by the target expression of the limit are returned.
+// Host name patterns of the limit only match the hosts of the steps.
+func (r Herd) GetHostsForSteps(steps []Step, limit string) ([][]Host, error) {
+	var stepHosts [][]Host
+	var hosts []Host
+
+	for _, step := range steps {
+		h, err := r.GetHostsForStep(step)
+		if err != nil {
+			return nil, err
 		}
+
+		stepHosts = append(stepHosts, h)
+		hosts = append(hosts, h...)
+	}
+
+	if limit == "" {
+		return stepHosts, nil
+	}
+
+	limiter, err := r.newHostLimiter(limit, hosts)
+	if err != nil {
+		return nil, err
 	}
 
-	for targetName, targetInfo := range t {
-		discoveredHosts, err := targetInfo.DiscoverHosts()
+	for i := range stepHosts {
+		limited, err := limiter.limit(stepHosts[i])
 		if err != nil {
 			return nil, err
 		}
 
-		connName, connInfo, err := r.GetConnection(targetName)
+		stepHosts[i] = limited
+	}
+
+	return stepHosts, nil
+}
+
+// hostLimiter limits hosts to those which are also selected by a target
+// expression. The targets of the expression are only discovered once,
+// so a hostLimiter can be reused by every step of a run.
+type hostLimiter struct {
+	expression string
+	selector   *hostSelector
+
+	// hosts are the hosts which are being limited.
+	hosts []*Host
+}
+
+// newHostLimiter will return a hostLimiter of a target expression.
+// hosts are the hosts of every step of the run. Each term of the
+// expression must select one of them, and so must the expression, so
+// a misspelled name is an error rather than a limit which selects no
+// hosts. Hosts are selected by name, as with intersections and
+// exclusions.
+func (r Herd) newHostLimiter(expression string, hosts []Host) (*hostLimiter, error) {
+	terms, err := parseTargetExpressions([]string{expression})
+	if err != nil {
+		return nil, err
+	}
+
+	limiter := &hostLimiter{
+		expression: expression,
+		selector:   newHostSelector(r),
+	}
+
+	limiter.selector.strict = false
+	limiter.selector.all = func() ([]*Host, error) {
+		return limiter.hosts, nil
+	}
+
+	limiter.setHosts(hosts)
+
+	names := make(map[string]bool)
+	for i := range hosts {
+		names[hosts[i].Name] = true
+	}
+
+	for _, term := range terms {
+		matched, err := limiter.selector.match(term.pattern)
 		if err != nil {
 			return nil, err
 		}
 
-		for _, host := range discoveredHosts {
-			if err := host.SetConnection(connName, connInfo); err != nil {
-				return nil, err
+		var found bool
+		for _, host := range matched {
+			if names[host.Name] {
+				found = true
+				break
 			}
+		}
 
-			hosts = append(hosts, host)
+		if !found {
+			return nil, fmt.Errorf("limit %s: %s does not match any host of the task", expression, term.pattern)
 		}
 	}
 
-	return hosts, nil
+	limited, err := limiter.limit(hosts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(limited) == 0 {
+		return nil, fmt.Errorf("limit %s does not select any host of the task", expression)
+	}
+
+	return limiter, nil
+}
+
+// setHosts will set the hosts which are being limited.
+func (r *hostLimiter) setHosts(hosts []Host) {
+	r.hosts = nil
+	for i := range hosts {
+		r.hosts = append(r.hosts, &hosts[i])
+	}
+}
+
+// limit returns the hosts which are also selected by the target
+// expression of the limiter. Host name patterns of the expression only
+// match the given hosts.
+func (r *hostLimiter) limit(hosts []Host) ([]Host, error) {
+	var limited []Host
+
+	r.setHosts(hosts)
+
+	selected, err := r.selector.selectHosts([]string{r.expression})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, host := range selected {
+		names[host.Name] = true
+	}
+
+	for i := range hosts {
+		if names[hosts[i].Name] {
+			limited = append(limited, hosts[i])
+		}
+	}
+
+	return limited, nil
 }
 
 // GetNotify returns a notify based on name.
@@ -225,6 +364,7 @@ func (r Herd) GetTarget(targetName string) (*Target, error) {
 	// If it was specified, build an explicit local target.
 	if targetName == "local" {
 		local := Target{
+			Name:    "local",
 			Type:    "local",
 			Options: map[string]interface{}{},
 		}
@@ -235,6 +375,8 @@ func (r Herd) GetTarget(targetName string) (*Target, error) {
 	allTargets := r.ListTargets()
 	for name, t := range allTargets {
 		if name == targetName {
+			// The name is set so discovered hosts know their target.
+			t.Name = name
 			return &t, nil
 		}
 	}
@@ -268,19 +410,29 @@ func (r Herd) ListTargets() map[string]Target {
 	return t
 }
 
-// ListStepsForTask will return a task group from a Herd.
-func (r Herd) ListStepsForTask(task string) ([]Step, error) {
+// GetTask returns a task based on name.
+func (r Herd) GetTask(task string) (*Task, error) {
 	taskName := fmt.Sprintf("task::%s", task)
 
 	for _, yak := range r {
 		if v, ok := yak.Tasks[taskName]; ok {
-			return v.Steps, nil
+			return &v, nil
 		}
 	}
 
 	return nil, fmt.Errorf("task %s not found", task)
 }
 
+// ListStepsForTask will return a task group from a Herd.
+func (r Herd) ListStepsForTask(task string) ([]Step, error) {
+	t, err := r.GetTask(task)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Steps, nil
+}
+
 // readYakfile will read a yaml file and parse it as a Yakfile.
 func readYakfile(file string) (*Yakfile, error) {
 	var yak Yakfile